@@ -0,0 +1,222 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AckFailure describes an acknowledgment the broker rejected.
+type AckFailure struct {
+	MessageID string `json:"message_id"`
+	Error     string `json:"error"`
+}
+
+// AckBatchResult reports the outcome of an AckBatch call.
+type AckBatchResult struct {
+	Acknowledged int          `json:"acknowledged"`
+	Failures     []AckFailure `json:"failures"`
+}
+
+// Err returns an error summarizing rejected acknowledgments, or nil if the
+// whole batch was accepted.
+func (r *AckBatchResult) Err() error {
+	if len(r.Failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d acknowledgments failed, first: %s: %s",
+		len(r.Failures), r.Acknowledged+len(r.Failures), r.Failures[0].MessageID, r.Failures[0].Error)
+}
+
+// Ack reports the outcome of processing a received message.
+func (c *Client) Ack(ack *Acknowledgment, opts ...CallOption) error {
+	return c.ack(context.Background(), ack, opts...)
+}
+
+func (c *Client) ack(ctx context.Context, ack *Acknowledgment, opts ...CallOption) error {
+	ack.encodeResult()
+	_, err := c.requestContext(ctx, "POST", "/messages/"+ack.OriginalMessageID+"/ack", ack, opts...)
+	return err
+}
+
+// GetAck gets the acknowledgment of a message. It returns ErrNotFound until
+// the message has been acknowledged.
+func (c *Client) GetAck(messageID string, opts ...CallOption) (*Acknowledgment, error) {
+	return c.getAck(context.Background(), messageID, opts...)
+}
+
+func (c *Client) getAck(ctx context.Context, messageID string, opts ...CallOption) (*Acknowledgment, error) {
+	data, err := c.requestContext(ctx, "GET", "/messages/"+messageID+"/ack", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAck(data)
+}
+
+// WaitForAck polls for the acknowledgment of a message sent without waiting
+// for its result, backing off exponentially up to 5s between polls. It
+// returns the acknowledgment whatever its status, or ctx's error once ctx
+// is done. Transient errors are retried; others are returned.
+func (c *Client) WaitForAck(ctx context.Context, messageID string, opts ...CallOption) (*Acknowledgment, error) {
+	for attempt := 0; ; attempt++ {
+		ack, err := c.getAck(ctx, messageID, opts...)
+		if err == nil {
+			return ack, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if !errors.Is(err, ErrNotFound) && !retryable(err) {
+			return nil, err
+		}
+		sleepContext(ctx, retryBackoff(attempt))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// AckBatch reports several acknowledgments in one request. A nil error means
+// the request succeeded; individual rejections are listed in the result.
+// Brokers that do not advertise FeatureBatching get one Ack per
+// acknowledgment instead.
+func (c *Client) AckBatch(acks []Acknowledgment, opts ...CallOption) (*AckBatchResult, error) {
+	return c.AckBatchContext(context.Background(), acks, opts...)
+}
+
+// AckBatchContext is AckBatch with a context bounding the request, and the
+// server info lookup that decides between one request and one per
+// acknowledgment.
+func (c *Client) AckBatchContext(ctx context.Context, acks []Acknowledgment, opts ...CallOption) (*AckBatchResult, error) {
+	if !c.supports(ctx, FeatureBatching, opts...) {
+		return c.ackEach(ctx, acks, opts...), nil
+	}
+	for i := range acks {
+		acks[i].encodeResult()
+	}
+
+	data, err := c.requestContext(ctx, "POST", "/acks/batch", map[string]interface{}{
+		"acknowledgments": acks,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result AckBatchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ackEach reports acknowledgments one at a time, collecting the outcomes
+// like a batch would.
+func (c *Client) ackEach(ctx context.Context, acks []Acknowledgment, opts ...CallOption) *AckBatchResult {
+	result := &AckBatchResult{}
+	for i := range acks {
+		if err := c.ack(ctx, &acks[i], opts...); err != nil {
+			result.Failures = append(result.Failures, AckFailure{
+				MessageID: acks[i].OriginalMessageID,
+				Error:     err.Error(),
+			})
+			continue
+		}
+		result.Acknowledged++
+	}
+	return result
+}
+
+// AckBatcher buffers acknowledgments and flushes them with AckBatch once
+// Size acknowledgments are pending or Interval has passed since the first
+// pending one, whichever comes first.
+type AckBatcher struct {
+	client   *Client
+	size     int
+	interval time.Duration
+
+	// OnError is called with the outcome of background flushes that fail
+	// or contain rejected acknowledgments.
+	OnError func(result *AckBatchResult, err error)
+
+	mu      sync.Mutex
+	pending []Acknowledgment
+	timer   *time.Timer
+}
+
+// NewAckBatcher creates an AckBatcher flushing every size acknowledgments or
+// interval, whichever comes first.
+func (c *Client) NewAckBatcher(size int, interval time.Duration) *AckBatcher {
+	if size <= 0 {
+		size = 100
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &AckBatcher{client: c, size: size, interval: interval}
+}
+
+// Add queues an acknowledgment. If the batch is full it is flushed
+// synchronously and the outcome returned.
+func (b *AckBatcher) Add(ack Acknowledgment) (*AckBatchResult, error) {
+	b.mu.Lock()
+	b.pending = append(b.pending, ack)
+	if len(b.pending) < b.size {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.interval, b.flushInBackground)
+		}
+		b.mu.Unlock()
+		return nil, nil
+	}
+	batch := b.take()
+	b.mu.Unlock()
+
+	return b.client.AckBatch(batch)
+}
+
+// Flush sends all pending acknowledgments immediately.
+func (b *AckBatcher) Flush() (*AckBatchResult, error) {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return &AckBatchResult{}, nil
+	}
+	return b.client.AckBatch(batch)
+}
+
+// Close flushes pending acknowledgments and stops the flush timer.
+func (b *AckBatcher) Close() error {
+	result, err := b.Flush()
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// take removes and returns the pending batch. b.mu must be held.
+func (b *AckBatcher) take() []Acknowledgment {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+func (b *AckBatcher) flushInBackground() {
+	result, err := b.Flush()
+	if b.OnError == nil {
+		return
+	}
+	if err != nil {
+		b.OnError(nil, err)
+	} else if result.Err() != nil {
+		b.OnError(result, nil)
+	}
+}
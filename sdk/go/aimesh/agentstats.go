@@ -0,0 +1,78 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrorCount is how often a failure code occurred.
+type ErrorCount struct {
+	Code  string `json:"code"`
+	Count int64  `json:"count"`
+}
+
+// AgentStats summarizes an agent's traffic over a window.
+type AgentStats struct {
+	AgentID           string
+	Window            time.Duration
+	MessagesSent      int64
+	MessagesProcessed int64
+	// SuccessRate is the fraction of processed messages acked successfully.
+	SuccessRate    float64
+	AvgLatency     time.Duration
+	TokensConsumed float64
+	// TopErrors are the most frequent failure codes, most frequent first.
+	TopErrors []ErrorCount
+}
+
+// UnmarshalJSON decodes the stats from the broker's wire format.
+func (s *AgentStats) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		AgentID           string       `json:"agent_id"`
+		WindowMs          int64        `json:"window_ms"`
+		MessagesSent      int64        `json:"messages_sent"`
+		MessagesProcessed int64        `json:"messages_processed"`
+		SuccessRate       float64      `json:"success_rate"`
+		AvgLatencyMs      float64      `json:"avg_latency_ms"`
+		TokensConsumed    float64      `json:"tokens_consumed"`
+		TopErrors         []ErrorCount `json:"top_errors"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*s = AgentStats{
+		AgentID:           wire.AgentID,
+		Window:            time.Duration(wire.WindowMs) * time.Millisecond,
+		MessagesSent:      wire.MessagesSent,
+		MessagesProcessed: wire.MessagesProcessed,
+		SuccessRate:       wire.SuccessRate,
+		AvgLatency:        time.Duration(wire.AvgLatencyMs * float64(time.Millisecond)),
+		TokensConsumed:    wire.TokensConsumed,
+		TopErrors:         wire.TopErrors,
+	}
+	return nil
+}
+
+// GetAgentStats gets an agent's traffic, latency, spend and failure codes
+// over the window ending now.
+func (c *Client) GetAgentStats(agentID string, window time.Duration, opts ...CallOption) (*AgentStats, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: stats window must be positive", ErrValidation)
+	}
+
+	v := url.Values{"window_ms": {strconv.FormatInt(window.Milliseconds(), 10)}}
+	data, err := c.request("GET", withQuery("/agents/"+agentID+"/stats", v), nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats AgentStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
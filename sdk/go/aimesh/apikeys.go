@@ -0,0 +1,142 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// API key scopes.
+const (
+	ScopeSend    = "send"
+	ScopeConsume = "consume"
+	ScopeAdmin   = "admin"
+)
+
+// APIKeySpec describes an API key to create.
+type APIKeySpec struct {
+	Name   string
+	Scopes []string
+	// AgentIDs restricts the key to these agents. Empty allows every agent.
+	AgentIDs []string
+	// TTL makes the key expire. Zero never expires.
+	TTL time.Duration
+}
+
+// SendOnlyKey describes a key that can only send to agentIDs.
+func SendOnlyKey(name string, agentIDs ...string) *APIKeySpec {
+	return &APIKeySpec{Name: name, Scopes: []string{ScopeSend}, AgentIDs: agentIDs}
+}
+
+// ConsumeOnlyKey describes a key that can only receive and acknowledge
+// messages of agentIDs.
+func ConsumeOnlyKey(name string, agentIDs ...string) *APIKeySpec {
+	return &APIKeySpec{Name: name, Scopes: []string{ScopeConsume}, AgentIDs: agentIDs}
+}
+
+// AdminKey describes a key with full access.
+func AdminKey(name string) *APIKeySpec {
+	return &APIKeySpec{Name: name, Scopes: []string{ScopeAdmin}}
+}
+
+// Validate checks the spec for values the broker would reject.
+func (s *APIKeySpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("%w: API key needs a name", ErrValidation)
+	}
+	if len(s.Scopes) == 0 {
+		return fmt.Errorf("%w: API key needs at least one scope", ErrValidation)
+	}
+	for _, scope := range s.Scopes {
+		switch scope {
+		case ScopeSend, ScopeConsume, ScopeAdmin:
+		default:
+			return fmt.Errorf("%w: unknown API key scope %q", ErrValidation, scope)
+		}
+	}
+	if s.TTL < 0 {
+		return fmt.Errorf("%w: API key TTL must not be negative", ErrValidation)
+	}
+	return nil
+}
+
+// APIKey is an API key known to the broker.
+type APIKey struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	AgentIDs  []string `json:"agent_ids"`
+	CreatedAt int64    `json:"created_at"`
+	// ExpiresAt is zero for keys that never expire.
+	ExpiresAt int64 `json:"expires_at"`
+	// Secret is the key itself. The broker only returns it on creation.
+	Secret string `json:"secret,omitempty"`
+}
+
+// Allows reports whether the key grants scope on agentID. Admin keys allow
+// everything.
+func (k *APIKey) Allows(scope, agentID string) bool {
+	if !contains(k.Scopes, ScopeAdmin) && !contains(k.Scopes, scope) {
+		return false
+	}
+	return len(k.AgentIDs) == 0 || contains(k.AgentIDs, agentID)
+}
+
+// APIKeyPage is one page of API keys.
+type APIKeyPage struct {
+	Keys       []APIKey `json:"keys"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+// CreateAPIKey creates an API key. The returned key's Secret is only
+// available now; store it, since the broker keeps only a hash.
+func (c *Client) CreateAPIKey(spec *APIKeySpec, opts ...CallOption) (*APIKey, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"name":   spec.Name,
+		"scopes": spec.Scopes,
+	}
+	if len(spec.AgentIDs) > 0 {
+		body["agent_ids"] = spec.AgentIDs
+	}
+	if spec.TTL > 0 {
+		body["ttl_ms"] = spec.TTL.Milliseconds()
+	}
+
+	data, err := c.request("POST", "/api-keys", body, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// ListAPIKeys lists API keys without their secrets. Pass the NextCursor of a
+// page as opts.Cursor to fetch the following page.
+func (c *Client) ListAPIKeys(opts *ListOptions, callOpts ...CallOption) (*APIKeyPage, error) {
+	data, err := c.request("GET", withQuery("/api-keys", opts.values()), nil, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page APIKeyPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// RevokeAPIKey revokes an API key. Requests using it fail from then on.
+func (c *Client) RevokeAPIKey(id string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/api-keys/"+id, nil, opts...)
+	return err
+}
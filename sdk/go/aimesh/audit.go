@@ -0,0 +1,86 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Audited actions. The broker records others too, such as API key and
+// policy changes.
+const (
+	AuditSend    = "send"
+	AuditCancel  = "cancel"
+	AuditPurge   = "purge"
+	AuditRequeue = "requeue"
+	AuditDelete  = "delete"
+)
+
+// AuditEvent records who did what to which resource.
+type AuditEvent struct {
+	ID string `json:"id"`
+	// Actor is the API key ID or agent that performed the action.
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	// Resource identifies what was acted on, such as messages/<id> or
+	// agents/<id>/queue.
+	Resource  string            `json:"resource"`
+	OrgID     string            `json:"org_id,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit events. Zero fields match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	// Resource matches resources starting with it.
+	Resource string
+	From     time.Time
+	To       time.Time
+}
+
+// AuditPage is one page of audit events.
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// GetAuditLog lists audit events matching filter, newest first. Pass the
+// NextCursor of a page as opts.Cursor to fetch the following page.
+func (c *Client) GetAuditLog(filter *AuditFilter, opts *ListOptions, callOpts ...CallOption) (*AuditPage, error) {
+	v := opts.values()
+	if filter != nil {
+		if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+			return nil, fmt.Errorf("%w: audit start must be before its end", ErrValidation)
+		}
+		if filter.Actor != "" {
+			v.Set("actor", filter.Actor)
+		}
+		if filter.Action != "" {
+			v.Set("action", filter.Action)
+		}
+		if filter.Resource != "" {
+			v.Set("resource", filter.Resource)
+		}
+		if !filter.From.IsZero() {
+			v.Set("from_ms", strconv.FormatInt(filter.From.UnixMilli(), 10))
+		}
+		if !filter.To.IsZero() {
+			v.Set("to_ms", strconv.FormatInt(filter.To.UnixMilli(), 10))
+		}
+	}
+
+	data, err := c.request("GET", withQuery("/audit", v), nil, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page AuditPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
@@ -0,0 +1,140 @@
+package aimesh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyProvider supplies the API key for each request, so keys can be rotated
+// without restarting agents.
+type KeyProvider interface {
+	APIKey(ctx context.Context) (string, error)
+}
+
+// KeyProviderFunc adapts a function to KeyProvider.
+type KeyProviderFunc func(ctx context.Context) (string, error)
+
+// APIKey implements KeyProvider.
+func (f KeyProviderFunc) APIKey(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticKey is a KeyProvider that always returns the same key.
+type StaticKey string
+
+// APIKey implements KeyProvider.
+func (k StaticKey) APIKey(ctx context.Context) (string, error) {
+	return string(k), nil
+}
+
+// authorizer supplies the Authorization header of broker requests.
+type authorizer interface {
+	// authorization returns the header value, or "" to send none.
+	authorization(ctx context.Context) (string, error)
+	// retry returns the header value to retry with after the broker refused
+	// sent with a 401, and false if there is none.
+	retry(ctx context.Context, sent string) (string, bool)
+}
+
+// refresher is implemented by key providers that cache keys, such as
+// CachedKeyProvider, so a refused key can be refetched.
+type refresher interface {
+	Refresh(ctx context.Context) (string, error)
+}
+
+// keyRing authorizes requests with API keys. After the broker refuses the
+// primary key, requests go straight to the secondary one until the provider
+// hands out a new primary.
+type keyRing struct {
+	provider  KeyProvider
+	secondary string
+
+	mu       sync.Mutex
+	rejected string
+}
+
+func (k *keyRing) authorization(ctx context.Context) (string, error) {
+	key, err := k.provider.APIKey(ctx)
+	if err != nil {
+		return "", fmt.Errorf("api key: %w", err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key == k.rejected && k.secondary != "" {
+		return bearer(k.secondary), nil
+	}
+	return bearer(key), nil
+}
+
+func (k *keyRing) retry(ctx context.Context, sent string) (string, bool) {
+	fetch := k.provider.APIKey
+	if r, ok := k.provider.(refresher); ok {
+		fetch = r.Refresh
+	}
+	key, err := fetch(ctx)
+	if err != nil {
+		return "", false
+	}
+	if bearer(key) != sent && key != "" {
+		return bearer(key), true
+	}
+	if k.secondary == "" || bearer(k.secondary) == sent {
+		return "", false
+	}
+	k.mu.Lock()
+	k.rejected = key
+	k.mu.Unlock()
+	return bearer(k.secondary), true
+}
+
+func bearer(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "Bearer " + key
+}
+
+// credentials returns the Authorization header to send with a call, or ""
+// if the call brings its own credentials.
+func (c *Client) credentials(ctx context.Context, o *callOptions) (string, error) {
+	if o.auth {
+		return "", nil
+	}
+	return c.auth.authorization(ctx)
+}
+
+// roundTrip sends a broker request with the auth Authorization header. If
+// the broker answers 401 and other credentials are available, the request
+// is retried once with them. Requests without credentials or carrying their
+// own Authorization header are not retried.
+func (c *Client) roundTrip(ctx context.Context, url, method string, data []byte, header http.Header, auth string) (*http.Response, error) {
+	resp, err := c.roundTripAuth(ctx, url, method, data, header, auth)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || auth == "" || header.Get("Authorization") != "" {
+		return resp, err
+	}
+	next, ok := c.auth.retry(ctx, auth)
+	if !ok {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return c.roundTripAuth(ctx, url, method, data, header, next)
+}
+
+func (c *Client) roundTripAuth(ctx context.Context, url, method string, data []byte, header http.Header, auth string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, url, method, data, header, auth)
+	if err != nil {
+		return nil, err
+	}
+	sent := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConnection, err)
+	}
+	c.clock.observeDate(sent, time.Now(), resp.Header.Get("Date"))
+	return resp, nil
+}
@@ -0,0 +1,192 @@
+package aimesh
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Balancer chooses which broker serves each request. Implementations must be
+// safe for concurrent use.
+type Balancer interface {
+	// Update replaces the set of brokers.
+	Update(brokers []string)
+	// Pick returns the brokers to try for a request, in order. The request
+	// moves to the next one while brokers are unavailable.
+	Pick() []string
+	// Report records the outcome of a request sent to a broker. The latency
+	// is zero for long polls, whose duration says nothing about the broker.
+	Report(broker string, latency time.Duration, err error)
+}
+
+// EjectionPolicy configures outlier ejection: a broker that keeps failing is
+// taken out of rotation for a while, and for longer each time it is ejected
+// again. Ejected brokers are still tried as a last resort.
+type EjectionPolicy struct {
+	// ConsecutiveFailures ejects a broker after this many connection errors
+	// or 503s in a row. Defaults to 5.
+	ConsecutiveFailures int
+	// BaseEjectionTime is how long a broker stays ejected the first time,
+	// multiplied by the number of times it has been ejected. Defaults to
+	// 30 seconds.
+	BaseEjectionTime time.Duration
+	// MaxEjectionPercent caps the share of brokers ejected at once.
+	// Defaults to 50.
+	MaxEjectionPercent int
+}
+
+// hostStats is the health of one broker.
+type hostStats struct {
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+	latency      time.Duration
+}
+
+// hostSet tracks per-broker health for the built-in balancers.
+type hostSet struct {
+	policy EjectionPolicy
+
+	mu    sync.Mutex
+	hosts []string
+	stats map[string]*hostStats
+}
+
+func newHostSet(policy *EjectionPolicy) *hostSet {
+	h := &hostSet{stats: make(map[string]*hostStats)}
+	if policy != nil {
+		h.policy = *policy
+	}
+	if h.policy.ConsecutiveFailures <= 0 {
+		h.policy.ConsecutiveFailures = 5
+	}
+	if h.policy.BaseEjectionTime <= 0 {
+		h.policy.BaseEjectionTime = 30 * time.Second
+	}
+	if h.policy.MaxEjectionPercent <= 0 {
+		h.policy.MaxEjectionPercent = 50
+	}
+	return h
+}
+
+func (h *hostSet) Update(brokers []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hosts = append([]string(nil), brokers...)
+	stats := make(map[string]*hostStats, len(brokers))
+	for _, b := range brokers {
+		if s, ok := h.stats[b]; ok {
+			stats[b] = s
+		} else {
+			stats[b] = &hostStats{}
+		}
+	}
+	h.stats = stats
+}
+
+func (h *hostSet) Report(broker string, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.stats[broker]
+	if !ok {
+		return
+	}
+	if !failsOver(err) {
+		s.failures = 0
+		if latency <= 0 {
+			return
+		}
+		if s.latency == 0 {
+			s.latency = latency
+		} else {
+			// Exponentially weighted moving average.
+			s.latency = (s.latency*4 + latency) / 5
+		}
+		return
+	}
+
+	s.failures++
+	now := time.Now()
+	if s.failures < h.policy.ConsecutiveFailures || now.Before(s.ejectedUntil) {
+		return
+	}
+	ejected := 0
+	for _, other := range h.stats {
+		if now.Before(other.ejectedUntil) {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > len(h.hosts)*h.policy.MaxEjectionPercent {
+		return
+	}
+	s.ejections++
+	s.ejectedUntil = now.Add(h.policy.BaseEjectionTime * time.Duration(s.ejections))
+	s.failures = 0
+}
+
+// split returns the brokers in rotation and the ejected ones.
+func (h *hostSet) split() (active, ejected []string) {
+	now := time.Now()
+	for _, b := range h.hosts {
+		if now.Before(h.stats[b].ejectedUntil) {
+			ejected = append(ejected, b)
+		} else {
+			active = append(active, b)
+		}
+	}
+	return active, ejected
+}
+
+// roundRobin rotates requests over the brokers in rotation.
+type roundRobin struct {
+	*hostSet
+	next int
+}
+
+// NewRoundRobinBalancer returns a Balancer that sends successive requests to
+// successive brokers, ejecting outliers according to policy. A nil policy
+// uses the EjectionPolicy defaults.
+func NewRoundRobinBalancer(policy *EjectionPolicy) Balancer {
+	return &roundRobin{hostSet: newHostSet(policy)}
+}
+
+func (r *roundRobin) Pick() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active, ejected := r.split()
+	out := make([]string, 0, len(active)+len(ejected))
+	if n := len(active); n > 0 {
+		start := r.next % n
+		r.next++
+		out = append(out, active[start:]...)
+		out = append(out, active[:start]...)
+	}
+	return append(out, ejected...)
+}
+
+// leastLatency prefers the broker with the lowest average latency.
+type leastLatency struct {
+	*hostSet
+}
+
+// NewLeastLatencyBalancer returns a Balancer that sends each request to the
+// broker with the lowest recent latency, ejecting outliers according to
+// policy. Brokers without measurements yet are tried first. A nil policy
+// uses the EjectionPolicy defaults.
+func NewLeastLatencyBalancer(policy *EjectionPolicy) Balancer {
+	return &leastLatency{hostSet: newHostSet(policy)}
+}
+
+func (l *leastLatency) Pick() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	active, ejected := l.split()
+	sort.SliceStable(active, func(i, j int) bool {
+		return l.stats[active[i]].latency < l.stats[active[j]].latency
+	})
+	return append(active, ejected...)
+}
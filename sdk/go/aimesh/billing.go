@@ -0,0 +1,254 @@
+package aimesh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BillingSink receives the cost reports of a BillingExporter. The SDK ships
+// webhook and CSV/JSON file sinks only; warehouse sinks, such as BigQuery or
+// Parquet files on S3, are left to the user, who implements BillingSink with
+// the warehouse's own client library so that the SDK does not depend on it.
+type BillingSink interface {
+	WriteReport(ctx context.Context, report *CostReport) error
+}
+
+// BillingSinkFunc adapts a function to BillingSink.
+type BillingSinkFunc func(ctx context.Context, report *CostReport) error
+
+// WriteReport implements BillingSink.
+func (f BillingSinkFunc) WriteReport(ctx context.Context, report *CostReport) error {
+	return f(ctx, report)
+}
+
+// WebhookSink posts each report as JSON to URL.
+type WebhookSink struct {
+	URL    string
+	Header http.Header
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// WriteReport implements BillingSink.
+func (s *WebhookSink) WriteReport(ctx context.Context, report *CostReport) error {
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, &buf)
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("billing webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// FileSink writes each report to its own file in Dir, named after the
+// report's period, ready to be loaded into a warehouse or synced to object
+// storage.
+type FileSink struct {
+	Dir string
+	// Format is "csv" or "json". Defaults to "csv".
+	Format string
+}
+
+// WriteReport implements BillingSink.
+func (s *FileSink) WriteReport(ctx context.Context, report *CostReport) error {
+	format := s.Format
+	if format == "" {
+		format = "csv"
+	}
+	write := report.WriteCSV
+	switch format {
+	case "csv":
+	case "json":
+		write = report.WriteJSON
+	default:
+		return fmt.Errorf("%w: unknown billing file format %q", ErrValidation, format)
+	}
+
+	name := fmt.Sprintf("cost-%s-%d-%d.%s", report.GroupBy, report.FromMs, report.ToMs, format)
+	path := filepath.Join(s.Dir, name)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// BillingExportOptions configures a BillingExporter.
+type BillingExportOptions struct {
+	// Interval is the length of each exported period. Defaults to an hour.
+	Interval time.Duration
+	// GroupBy is the report grouping. Defaults to GroupByAgent.
+	GroupBy string
+	// From is the start of the first period. Defaults to one interval ago.
+	From time.Time
+}
+
+// BillingExporter periodically exports cost reports to a sink. Periods are
+// contiguous: a period whose export fails is retried on the next tick, so
+// the sink sees every period exactly once unless it fails after writing.
+type BillingExporter struct {
+	client   *Client
+	sink     BillingSink
+	interval time.Duration
+	groupBy  string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	from    time.Time
+	lastErr error
+}
+
+// StartBillingExporter exports cost reports to sink every interval until
+// Stop or Close is called.
+func (c *Client) StartBillingExporter(sink BillingSink, opts *BillingExportOptions) (*BillingExporter, error) {
+	var o BillingExportOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Hour
+	}
+	if o.GroupBy == "" {
+		o.GroupBy = GroupByAgent
+	}
+	switch o.GroupBy {
+	case GroupByAgent, GroupByTaskGraph, GroupByEndpoint:
+	default:
+		return nil, fmt.Errorf("%w: unknown cost report grouping %q", ErrValidation, o.GroupBy)
+	}
+	if o.From.IsZero() {
+		o.From = time.Now().Add(-o.Interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &BillingExporter{
+		client:   c,
+		sink:     sink,
+		interval: o.Interval,
+		groupBy:  o.GroupBy,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		from:     o.From,
+	}
+	if err := c.track(e); err != nil {
+		cancel()
+		return nil, err
+	}
+	go e.run(ctx)
+	return e, nil
+}
+
+func (e *BillingExporter) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		err := e.export(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		e.mu.Lock()
+		e.lastErr = err
+		e.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// export exports every complete period since the last successful one.
+func (e *BillingExporter) export(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		from := e.from
+		e.mu.Unlock()
+
+		to := from.Add(e.interval)
+		if to.After(time.Now()) {
+			return nil
+		}
+		report, err := e.client.exportCostReport(ctx, from, to, e.groupBy)
+		if err != nil {
+			return err
+		}
+		if err := e.sink.WriteReport(ctx, report); err != nil {
+			return err
+		}
+
+		e.mu.Lock()
+		e.from = to
+		e.mu.Unlock()
+	}
+}
+
+// Next returns the start of the next period to export.
+func (e *BillingExporter) Next() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.from
+}
+
+// Err returns the error of the most recent export, or nil if it succeeded.
+func (e *BillingExporter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastErr
+}
+
+// Stop stops exporting. It may be called from the sink.
+func (e *BillingExporter) Stop() {
+	e.client.untrack(e)
+	e.cancel()
+}
+
+func (e *BillingExporter) drain(ctx context.Context) error {
+	e.Stop()
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package aimesh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// failover spreads requests over brokers in priority order: the first broker
+// that is not marked down gets all traffic. A broker is marked down when a
+// request to it fails with a connection error or a 503, and the request moves
+// on to the next one. While any broker is down its health endpoint is probed
+// in the background, and traffic returns to it once the probe succeeds.
+// It is the default Balancer.
+type failover struct {
+	client   *Client
+	interval time.Duration
+
+	mu      sync.Mutex
+	urls    []string
+	down    map[string]bool
+	probing bool
+	stopped bool
+	stop    chan struct{}
+}
+
+func newFailover(c *Client, interval time.Duration) *failover {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &failover{
+		client:   c,
+		interval: interval,
+		down:     make(map[string]bool),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Update replaces the brokers, given in priority order.
+func (f *failover) Update(brokers []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.urls = append([]string(nil), brokers...)
+	down := make(map[string]bool)
+	for _, u := range f.urls {
+		if f.down[u] {
+			down[u] = true
+		}
+	}
+	f.down = down
+}
+
+// Pick returns healthy brokers in priority order, then the ones marked down
+// as a last resort.
+func (f *failover) Pick() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]string, 0, len(f.urls))
+	for _, u := range f.urls {
+		if !f.down[u] {
+			out = append(out, u)
+		}
+	}
+	for _, u := range f.urls {
+		if f.down[u] {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// Report records the outcome of a request to a broker.
+func (f *failover) Report(url string, latency time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !failsOver(err) {
+		delete(f.down, url)
+		return
+	}
+	if len(f.urls) < 2 {
+		return
+	}
+	f.down[url] = true
+	if !f.probing && !f.stopped {
+		f.probing = true
+		go f.probe()
+	}
+}
+
+// probe health-checks the brokers marked down until all have recovered.
+func (f *failover) probe() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-f.stop:
+			return
+		}
+
+		f.mu.Lock()
+		if f.stopped {
+			f.mu.Unlock()
+			return
+		}
+		var down []string
+		for _, u := range f.urls {
+			if f.down[u] {
+				down = append(down, u)
+			}
+		}
+		if len(down) == 0 {
+			f.probing = false
+			f.mu.Unlock()
+			return
+		}
+		f.mu.Unlock()
+
+		for _, u := range down {
+			_, err := f.client.do(context.Background(), u, "GET", "/health", nil, &callOptions{timeout: f.interval})
+			if err == nil {
+				f.mu.Lock()
+				delete(f.down, u)
+				f.mu.Unlock()
+			}
+		}
+	}
+}
+
+// close stops background probing. It is called by Client.Close.
+func (f *failover) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.stopped {
+		f.stopped = true
+		close(f.stop)
+	}
+}
+
+// failsOver reports whether a request error means the broker itself is
+// unavailable, so that the request should move to the next broker.
+func failsOver(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrConnection) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable
+}
@@ -0,0 +1,499 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// BudgetAlert watches an agent's budget utilization and calls its callback
+// each time utilization crosses the threshold from below.
+type BudgetAlert struct {
+	client    *Client
+	agentID   string
+	threshold float64
+	fn        func(info *BudgetInfo)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// RegisterBudgetAlert calls fn when the utilization of agentID's budget
+// reaches thresholdPercent, so the agent can be throttled before sends fail
+// with ErrBudgetExceeded. The budget is polled every
+// ClientConfig.BudgetPollInterval. The alert fires again only after
+// utilization has dropped below the threshold, for example after a reset.
+// fn runs on the polling goroutine.
+func (c *Client) RegisterBudgetAlert(agentID string, thresholdPercent float64, fn func(info *BudgetInfo)) (*BudgetAlert, error) {
+	if thresholdPercent <= 0 || thresholdPercent > 100 {
+		return nil, fmt.Errorf("%w: threshold %v outside 0-100", ErrValidation, thresholdPercent)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &BudgetAlert{
+		client:    c,
+		agentID:   agentID,
+		threshold: thresholdPercent,
+		fn:        fn,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	if err := c.track(a); err != nil {
+		cancel()
+		return nil, err
+	}
+	go a.run(ctx)
+	return a, nil
+}
+
+func (a *BudgetAlert) run(ctx context.Context) {
+	defer close(a.done)
+	interval := a.client.budgetPoll
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	fired := false
+	for {
+		data, err := a.client.requestContext(ctx, "GET", "/budgets/"+a.agentID, nil)
+		if err == nil {
+			var info BudgetInfo
+			if json.Unmarshal(data, &info) == nil {
+				above := info.UtilizationPercent() >= a.threshold
+				if above && !fired {
+					a.fn(&info)
+				}
+				fired = above
+			}
+		}
+		sleepContext(ctx, interval)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Stop stops watching the budget. It may be called from the callback.
+func (a *BudgetAlert) Stop() {
+	a.client.untrack(a)
+	a.cancel()
+}
+
+func (a *BudgetAlert) drain(ctx context.Context) error {
+	a.Stop()
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// InsufficientBalanceError is returned by TransferBudget when the source
+// agent has fewer remaining tokens than requested. It matches
+// ErrBudgetExceeded with errors.Is.
+type InsufficientBalanceError struct {
+	AgentID   string
+	Available float64
+	Requested float64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("insufficient balance: agent %s has %.2f tokens, %.2f requested", e.AgentID, e.Available, e.Requested)
+}
+
+// Unwrap returns ErrBudgetExceeded.
+func (e *InsufficientBalanceError) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+// BudgetTransfer is the result of a TransferBudget call.
+type BudgetTransfer struct {
+	From BudgetInfo `json:"from"`
+	To   BudgetInfo `json:"to"`
+}
+
+// TransferBudget atomically moves tokens from one agent's remaining budget
+// to another's: either both budgets change or neither does. If the source
+// has too few tokens the error is an *InsufficientBalanceError.
+func (c *Client) TransferBudget(fromAgentID, toAgentID string, tokens float64, opts ...CallOption) (*BudgetTransfer, error) {
+	if tokens <= 0 {
+		return nil, fmt.Errorf("%w: transfer amount must be positive", ErrValidation)
+	}
+	if fromAgentID == toAgentID {
+		return nil, fmt.Errorf("%w: cannot transfer budget to the same agent", ErrValidation)
+	}
+
+	data, err := c.request("POST", "/budgets/transfers", map[string]interface{}{
+		"from_agent_id": fromAgentID,
+		"to_agent_id":   toAgentID,
+		"tokens":        tokens,
+	}, opts...)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		var body struct {
+			Code      string  `json:"code"`
+			Available float64 `json:"available_tokens"`
+		}
+		if json.Unmarshal(apiErr.Body, &body) == nil && body.Code == "insufficient_balance" {
+			return nil, &InsufficientBalanceError{AgentID: fromAgentID, Available: body.Available, Requested: tokens}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.InvalidateBudget(fromAgentID)
+	c.InvalidateBudget(toAgentID)
+
+	var transfer BudgetTransfer
+	if err := json.Unmarshal(data, &transfer); err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// BudgetPool is a token allowance shared by a group of agents.
+type BudgetPool struct {
+	PoolID          string   `json:"pool_id"`
+	InitialTokens   float64  `json:"initial_tokens"`
+	RemainingTokens float64  `json:"remaining_tokens"`
+	Agents          []string `json:"agents"`
+	ResetAt         int64    `json:"reset_at"`
+}
+
+// UtilizationPercent returns pool utilization percentage.
+func (p *BudgetPool) UtilizationPercent() float64 {
+	if p.InitialTokens == 0 {
+		return 0
+	}
+	return (p.InitialTokens - p.RemainingTokens) / p.InitialTokens * 100
+}
+
+// CreatePool creates a shared budget pool with the given allowance.
+func (c *Client) CreatePool(poolID string, tokens float64, opts ...CallOption) (*BudgetPool, error) {
+	data, err := c.request("POST", "/budget-pools", map[string]interface{}{
+		"pool_id": poolID,
+		"tokens":  tokens,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool BudgetPool
+	if err := json.Unmarshal(data, &pool); err != nil {
+		return nil, err
+	}
+
+	return &pool, nil
+}
+
+// GetPool gets a budget pool.
+func (c *Client) GetPool(poolID string, opts ...CallOption) (*BudgetPool, error) {
+	data, err := c.request("GET", "/budget-pools/"+poolID, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool BudgetPool
+	if err := json.Unmarshal(data, &pool); err != nil {
+		return nil, err
+	}
+
+	return &pool, nil
+}
+
+// DeletePool deletes a budget pool. Its agents fall back to their own
+// budgets.
+func (c *Client) DeletePool(poolID string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/budget-pools/"+poolID, nil, opts...)
+	return err
+}
+
+// AttachAgent makes an agent's messages charge the pool instead of the
+// agent's own budget.
+func (c *Client) AttachAgent(poolID, agentID string, opts ...CallOption) error {
+	_, err := c.request("PUT", "/budget-pools/"+poolID+"/agents/"+agentID, nil, opts...)
+	return err
+}
+
+// DetachAgent removes an agent from a pool.
+func (c *Client) DetachAgent(poolID, agentID string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/budget-pools/"+poolID+"/agents/"+agentID, nil, opts...)
+	return err
+}
+
+// WithBudgetPool charges the message to a budget pool, whether or not its
+// agent is attached to it.
+func (m *Message) WithBudgetPool(poolID string) *Message {
+	m.BudgetPoolID = poolID
+	return m
+}
+
+// GetBudgets gets the budgets of several agents in one request. Agents
+// without a budget are left out of the result.
+func (c *Client) GetBudgets(agentIDs []string, opts ...CallOption) ([]BudgetInfo, error) {
+	data, err := c.request("POST", "/budgets/batch", map[string]interface{}{
+		"agent_ids": agentIDs,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Budgets []BudgetInfo `json:"budgets"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Budgets, nil
+}
+
+// BudgetPage is one page of budgets.
+type BudgetPage struct {
+	Budgets    []BudgetInfo `json:"budgets"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// BudgetListOptions controls ListBudgets.
+type BudgetListOptions struct {
+	ListOptions
+	// MinUtilization only lists budgets at least this many percent used.
+	MinUtilization float64
+	// ResettingWithin only lists budgets that reset within this duration.
+	ResettingWithin time.Duration
+}
+
+// ListBudgets lists agent budgets across all agents. Pass the NextCursor of
+// a page as opts.Cursor to fetch the following page.
+func (c *Client) ListBudgets(opts *BudgetListOptions, callOpts ...CallOption) (*BudgetPage, error) {
+	if opts == nil {
+		opts = &BudgetListOptions{}
+	}
+	if opts.MinUtilization < 0 || opts.MinUtilization > 100 {
+		return nil, fmt.Errorf("%w: utilization filter %v outside 0-100", ErrValidation, opts.MinUtilization)
+	}
+	if opts.ResettingWithin < 0 {
+		return nil, fmt.Errorf("%w: reset window must not be negative", ErrValidation)
+	}
+
+	v := opts.values()
+	if opts.MinUtilization > 0 {
+		v.Set("min_utilization", strconv.FormatFloat(opts.MinUtilization, 'f', -1, 64))
+	}
+	if opts.ResettingWithin > 0 {
+		v.Set("resetting_within_ms", strconv.FormatInt(opts.ResettingWithin.Milliseconds(), 10))
+	}
+	data, err := c.request("GET", withQuery("/budgets", v), nil, append([]CallOption{conditional()}, callOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page BudgetPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// BudgetPoint is one interval of a budget history.
+type BudgetPoint struct {
+	// TimestampMs is the start of the interval.
+	TimestampMs int64 `json:"timestamp_ms"`
+	// TokensSpent is the spend during the interval.
+	TokensSpent float64 `json:"tokens_spent"`
+	// RemainingTokens is the balance at the end of the interval.
+	RemainingTokens float64 `json:"remaining_tokens"`
+}
+
+// BudgetHistory is a timeseries of an agent's spend.
+type BudgetHistory struct {
+	AgentID      string        `json:"agent_id"`
+	ResolutionMs int64         `json:"resolution_ms"`
+	Points       []BudgetPoint `json:"points"`
+}
+
+// TotalSpent returns the spend over the whole history.
+func (h *BudgetHistory) TotalSpent() float64 {
+	total := 0.0
+	for _, p := range h.Points {
+		total += p.TokensSpent
+	}
+	return total
+}
+
+// GetBudgetHistory gets an agent's spend between from and to, bucketed by
+// resolution, for charting burn rate and spotting runaway agents.
+func (c *Client) GetBudgetHistory(agentID string, from, to time.Time, resolution time.Duration, opts ...CallOption) (*BudgetHistory, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: history start must be before its end", ErrValidation)
+	}
+	if resolution <= 0 {
+		return nil, fmt.Errorf("%w: history resolution must be positive", ErrValidation)
+	}
+
+	v := url.Values{
+		"from_ms":       {strconv.FormatInt(from.UnixMilli(), 10)},
+		"to_ms":         {strconv.FormatInt(to.UnixMilli(), 10)},
+		"resolution_ms": {strconv.FormatInt(resolution.Milliseconds(), 10)},
+	}
+	data, err := c.request("GET", withQuery("/budgets/"+agentID+"/history", v), nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var history BudgetHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+// BudgetForecast projects when a budget runs out at its current burn rate.
+type BudgetForecast struct {
+	RemainingTokens float64
+	// BurnRate is the projected spend in tokens per second.
+	BurnRate float64
+	// Exhausts is false when nothing is being spent.
+	Exhausts         bool
+	TimeToExhaustion time.Duration
+	ExhaustsAt       time.Time
+	// BeforeReset reports whether the budget runs out before its next
+	// reset, which is when an alert is warranted.
+	BeforeReset bool
+}
+
+// forecastWindow is the number of most recent history points averaged for
+// the burn rate.
+const forecastWindow = 12
+
+// Forecast projects time to exhaustion. The burn rate is the average spend
+// over the most recent points of history when one is given, which reacts to
+// bursts faster than the broker's long-run ConsumptionRate (in tokens per
+// second) used otherwise.
+func (b *BudgetInfo) Forecast(history *BudgetHistory) *BudgetForecast {
+	rate := b.ConsumptionRate
+	if history != nil && len(history.Points) > 0 && history.ResolutionMs > 0 {
+		points := history.Points
+		if len(points) > forecastWindow {
+			points = points[len(points)-forecastWindow:]
+		}
+		spent := 0.0
+		for _, p := range points {
+			spent += p.TokensSpent
+		}
+		window := time.Duration(len(points)) * time.Duration(history.ResolutionMs) * time.Millisecond
+		rate = spent / window.Seconds()
+	}
+
+	f := &BudgetForecast{RemainingTokens: b.RemainingTokens, BurnRate: rate}
+	if rate <= 0 {
+		return f
+	}
+	f.Exhausts = true
+	remaining := b.RemainingTokens
+	if remaining < 0 {
+		remaining = 0
+	}
+	f.TimeToExhaustion = time.Duration(remaining / rate * float64(time.Second))
+	f.ExhaustsAt = time.Now().Add(f.TimeToExhaustion)
+	f.BeforeReset = b.ResetAt == 0 || f.ExhaustsAt.Before(time.Unix(0, b.ResetAt))
+	return f
+}
+
+// BudgetPolicy lets the broker refill an agent's budget on a schedule
+// instead of ResetBudget being called from cron.
+type BudgetPolicy struct {
+	// RefillAmount is added to the balance every RefillInterval.
+	RefillAmount   float64
+	RefillInterval time.Duration
+	// RolloverCap limits how many unused tokens are carried into the next
+	// interval; the rest is forfeited at refill. Zero carries nothing over,
+	// a negative value carries everything.
+	RolloverCap float64
+	// Ceiling is a hard maximum for the balance after a refill. Zero means
+	// no ceiling.
+	Ceiling float64
+}
+
+// MarshalJSON encodes the policy in the broker's wire format.
+func (p BudgetPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"refill_amount":      p.RefillAmount,
+		"refill_interval_ms": p.RefillInterval.Milliseconds(),
+		"rollover_cap":       p.RolloverCap,
+		"ceiling":            p.Ceiling,
+	})
+}
+
+// UnmarshalJSON decodes the policy from the broker's wire format.
+func (p *BudgetPolicy) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		RefillAmount     float64 `json:"refill_amount"`
+		RefillIntervalMs int64   `json:"refill_interval_ms"`
+		RolloverCap      float64 `json:"rollover_cap"`
+		Ceiling          float64 `json:"ceiling"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*p = BudgetPolicy{
+		RefillAmount:   wire.RefillAmount,
+		RefillInterval: time.Duration(wire.RefillIntervalMs) * time.Millisecond,
+		RolloverCap:    wire.RolloverCap,
+		Ceiling:        wire.Ceiling,
+	}
+	return nil
+}
+
+// Validate checks the policy for values the broker would reject.
+func (p *BudgetPolicy) Validate() error {
+	if p.RefillAmount <= 0 {
+		return fmt.Errorf("%w: refill amount must be positive", ErrValidation)
+	}
+	if p.RefillInterval < time.Minute {
+		return fmt.Errorf("%w: refill interval must be at least a minute", ErrValidation)
+	}
+	if p.Ceiling < 0 {
+		return fmt.Errorf("%w: ceiling must not be negative", ErrValidation)
+	}
+	if p.Ceiling > 0 && p.Ceiling < p.RefillAmount {
+		return fmt.Errorf("%w: ceiling %.2f is below the refill amount %.2f", ErrValidation, p.Ceiling, p.RefillAmount)
+	}
+	return nil
+}
+
+// SetBudgetPolicy sets the refill policy for an agent's budget.
+func (c *Client) SetBudgetPolicy(agentID string, policy *BudgetPolicy, opts ...CallOption) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	_, err := c.request("PUT", "/budgets/"+agentID+"/policy", policy, opts...)
+	return err
+}
+
+// GetBudgetPolicy gets the refill policy for an agent's budget.
+func (c *Client) GetBudgetPolicy(agentID string, opts ...CallOption) (*BudgetPolicy, error) {
+	data, err := c.request("GET", "/budgets/"+agentID+"/policy", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy BudgetPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// DeleteBudgetPolicy stops automatic refills for an agent's budget.
+func (c *Client) DeleteBudgetPolicy(agentID string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/budgets/"+agentID+"/policy", nil, opts...)
+	return err
+}
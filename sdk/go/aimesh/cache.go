@@ -0,0 +1,144 @@
+package aimesh
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheConfig enables client-side caching of frequently repeated reads, for
+// producers that check budgets and endpoints before every send. Zero TTLs
+// leave the matching read uncached.
+type CacheConfig struct {
+	// BudgetTTL is how long GetBudget results are reused.
+	BudgetTTL time.Duration
+	// EndpointTTL is how long GetEndpoint results are reused.
+	EndpointTTL time.Duration
+	// EndpointListTTL is how long ListEndpoints results are reused.
+	EndpointListTTL time.Duration
+}
+
+// WithoutCache makes a read go to the broker instead of using the cache or
+// sharing an identical read's result, and refreshes the cache with it.
+func WithoutCache() CallOption {
+	return func(o *callOptions) {
+		o.noCache = true
+	}
+}
+
+type cacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// maxCacheEntries bounds the number of reads the cache holds.
+const maxCacheEntries = 1024
+
+// readCache holds response bodies of cached reads, keyed by readKey.
+type readCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func (rc *readCache) get(key string) ([]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (rc *readCache) put(key string, body []byte, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.entries == nil {
+		rc.entries = make(map[string]cacheEntry)
+	}
+	now := time.Now()
+	if len(rc.entries) >= maxCacheEntries {
+		for k, e := range rc.entries {
+			if now.After(e.expires) {
+				delete(rc.entries, k)
+			}
+		}
+		// Map iteration order is random, so this evicts live entries at
+		// random once nothing has expired.
+		for k := range rc.entries {
+			if len(rc.entries) < maxCacheEntries {
+				break
+			}
+			delete(rc.entries, k)
+		}
+	}
+	rc.entries[key] = cacheEntry{body: body, expires: now.Add(ttl)}
+}
+
+// invalidate drops the entries for path, whatever their query string or
+// headers.
+func (rc *readCache) invalidate(path string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for k := range rc.entries {
+		if keyPath(k) == path {
+			delete(rc.entries, k)
+		}
+	}
+}
+
+// cached returns the internal option caching a read for ttl.
+func cached(ttl time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.cacheTTL = ttl
+	}
+}
+
+// budgetTTL, endpointTTL and endpointListTTL return the configured TTLs,
+// zero when caching is off.
+func (c *Client) budgetTTL() time.Duration {
+	if c.cacheConfig == nil {
+		return 0
+	}
+	return c.cacheConfig.BudgetTTL
+}
+
+func (c *Client) endpointTTL() time.Duration {
+	if c.cacheConfig == nil {
+		return 0
+	}
+	return c.cacheConfig.EndpointTTL
+}
+
+func (c *Client) endpointListTTL() time.Duration {
+	if c.cacheConfig == nil {
+		return 0
+	}
+	return c.cacheConfig.EndpointListTTL
+}
+
+// InvalidateBudget drops the cached budget of agentID. The client calls it
+// itself after SetBudget, ResetBudget and TransferBudget.
+func (c *Client) InvalidateBudget(agentID string) {
+	c.invalidate("/budgets/" + agentID)
+}
+
+// InvalidateEndpoint drops the cached endpoint and all cached endpoint
+// lists. The client calls it itself after changing an endpoint.
+func (c *Client) InvalidateEndpoint(endpointID string) {
+	c.invalidate("/endpoints/" + endpointID)
+	c.invalidate("/endpoints")
+}
+
+// invalidate drops cached and recently shared reads of path.
+func (c *Client) invalidate(path string) {
+	c.cache.invalidate(path)
+	c.flights.forgetPath(path)
+}
+
+// InvalidateCache drops every cached and recently shared read.
+func (c *Client) InvalidateCache() {
+	c.cache.mu.Lock()
+	c.cache.entries = nil
+	c.cache.mu.Unlock()
+	c.flights.forgetAll()
+}
@@ -0,0 +1,92 @@
+package aimesh
+
+import "fmt"
+
+// Modalities an endpoint can accept or produce.
+const (
+	ModalityText  = "text"
+	ModalityImage = "image"
+	ModalityAudio = "audio"
+	ModalityVideo = "video"
+)
+
+// EndpointCapabilities advertises what an endpoint can serve, so the broker
+// only routes compatible work to it.
+type EndpointCapabilities struct {
+	Models           []string `json:"models,omitempty"`
+	MaxContextTokens int      `json:"max_context_tokens,omitempty"`
+	Modalities       []string `json:"modalities,omitempty"`
+	Quantization     string   `json:"quantization,omitempty"`
+}
+
+// Requirements declares what a message needs from the endpoint that serves
+// it. Empty fields impose no constraint.
+type Requirements struct {
+	// Models lists acceptable models; any one of them will do.
+	Models []string `json:"models,omitempty"`
+	// MinContextTokens is the context length the message needs.
+	MinContextTokens int `json:"min_context_tokens,omitempty"`
+	// Modalities must all be supported.
+	Modalities []string `json:"modalities,omitempty"`
+	// Quantizations lists acceptable quantizations.
+	Quantizations []string `json:"quantizations,omitempty"`
+}
+
+// Validate checks the requirements for obvious mistakes.
+func (r *Requirements) Validate() error {
+	if r.MinContextTokens < 0 {
+		return fmt.Errorf("%w: min_context_tokens must not be negative", ErrValidation)
+	}
+	return nil
+}
+
+// WithRequirements restricts the message to endpoints whose capabilities
+// satisfy r.
+func (m *Message) WithRequirements(r *Requirements) *Message {
+	m.Requirements = r
+	return m
+}
+
+// Satisfies reports whether an endpoint with these capabilities can serve a
+// message with requirements r, mirroring the broker's routing decision.
+func (c *EndpointCapabilities) Satisfies(r *Requirements) bool {
+	if r == nil {
+		return true
+	}
+	if c == nil {
+		c = &EndpointCapabilities{}
+	}
+	if len(r.Models) > 0 && !intersects(r.Models, c.Models) {
+		return false
+	}
+	if r.MinContextTokens > 0 && c.MaxContextTokens < r.MinContextTokens {
+		return false
+	}
+	for _, m := range r.Modalities {
+		if !contains(c.Modalities, m) {
+			return false
+		}
+	}
+	if len(r.Quantizations) > 0 && !contains(r.Quantizations, c.Quantization) {
+		return false
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		if contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+package aimesh
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CheckpointStore persists submitted task graphs and the nodes that have
+// completed, so an orchestrator can resume a graph after a crash without
+// re-running, and re-paying for, finished nodes.
+type CheckpointStore interface {
+	// SaveGraph stores the serialized definition of a task graph.
+	SaveGraph(taskGraphID string, data []byte) error
+	// LoadGraph returns the definition stored by SaveGraph, or an error
+	// wrapping ErrNotFound.
+	LoadGraph(taskGraphID string) ([]byte, error)
+	// MarkCompleted records nodes of a task graph as completed.
+	MarkCompleted(taskGraphID string, nodeIDs ...string) error
+	// Completed returns the nodes recorded by MarkCompleted.
+	Completed(taskGraphID string) ([]string, error)
+}
+
+// graphSnapshot is the serialized form of a TaskGraph kept in a
+// CheckpointStore.
+type graphSnapshot struct {
+	TaskGraphID         string         `json:"task_graph_id"`
+	DefaultRetry        *RetryPolicy   `json:"default_retry,omitempty"`
+	CompensateOnFailure bool           `json:"compensate_on_failure,omitempty"`
+	Nodes               []nodeSnapshot `json:"nodes"`
+}
+
+// nodeSnapshot keeps deadlines relative to the submission, so that a
+// resumed node gets as long to run as it had originally.
+type nodeSnapshot struct {
+	Message                  Message      `json:"message"`
+	DeadlineInMs             int64        `json:"deadline_in_ms,omitempty"`
+	Retry                    *RetryPolicy `json:"retry,omitempty"`
+	Compensation             *Message     `json:"compensation,omitempty"`
+	CompensationDeadlineInMs int64        `json:"compensation_deadline_in_ms,omitempty"`
+}
+
+func (g *TaskGraph) snapshot() *graphSnapshot {
+	snap := &graphSnapshot{
+		TaskGraphID:         g.ID,
+		DefaultRetry:        g.DefaultRetry,
+		CompensateOnFailure: g.CompensateOnFailure,
+	}
+	for _, id := range g.order {
+		n := g.nodes[id]
+		node := nodeSnapshot{Message: *n.msg, DeadlineInMs: deadlineIn(n.msg), Retry: n.retry, Compensation: n.compensation}
+		if n.compensation != nil {
+			node.CompensationDeadlineInMs = deadlineIn(n.compensation)
+		}
+		snap.Nodes = append(snap.Nodes, node)
+	}
+	return snap
+}
+
+// Checkpoint fetches the state of a task graph and records the succeeded
+// nodes not yet recorded in the client's CheckpointStore. Orchestrators call it periodically,
+// or whenever they observe progress, while a graph runs.
+func (c *Client) Checkpoint(taskGraphID string, opts ...CallOption) (*TaskGraphStatus, error) {
+	if c.checkpoints == nil {
+		return nil, fmt.Errorf("%w: no checkpoint store configured", ErrValidation)
+	}
+
+	status, err := c.GetTaskGraph(taskGraphID, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := c.checkpoints.Completed(taskGraphID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(recorded))
+	for _, id := range recorded {
+		seen[id] = true
+	}
+	var done []string
+	for _, n := range status.Nodes {
+		if n.State == NodeSucceeded && !seen[n.NodeID] {
+			done = append(done, n.NodeID)
+		}
+	}
+	if len(done) > 0 {
+		if err := c.checkpoints.MarkCompleted(taskGraphID, done...); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// ResumeTaskGraph re-submits the incomplete part of a checkpointed task
+// graph. Completed nodes are dropped and dependencies on them are treated as
+// satisfied. The broker is asked for the latest state first, so nodes that
+// finished after the last Checkpoint are skipped too. Resubmitted nodes get
+// a fresh timestamp and a deadline as far after it as they had when first
+// submitted. It returns the graph that was submitted.
+func (c *Client) ResumeTaskGraph(taskGraphID string, opts ...CallOption) (*TaskGraph, error) {
+	if c.checkpoints == nil {
+		return nil, fmt.Errorf("%w: no checkpoint store configured", ErrValidation)
+	}
+
+	data, err := c.checkpoints.LoadGraph(taskGraphID)
+	if err != nil {
+		return nil, err
+	}
+	var snap graphSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+
+	// The broker may have lost the graph along with the orchestrator; in
+	// that case the stored checkpoints are all there is.
+	if _, err := c.Checkpoint(taskGraphID, opts...); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	completed, err := c.checkpoints.Completed(taskGraphID)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(completed))
+	for _, id := range completed {
+		done[id] = true
+	}
+
+	g := c.NewTaskGraph()
+	g.ID = snap.TaskGraphID
+	g.DefaultRetry = snap.DefaultRetry
+	g.CompensateOnFailure = snap.CompensateOnFailure
+	g.resume = true
+	for _, n := range snap.Nodes {
+		if done[n.Message.MessageID] {
+			continue
+		}
+		msg := n.Message
+		msg.decodePayload()
+		deps := make([]string, 0, len(msg.Dependencies))
+		for _, dep := range msg.Dependencies {
+			if !done[dep] {
+				deps = append(deps, dep)
+			}
+		}
+		msg.Dependencies = deps
+		msg.rebase(n.DeadlineInMs)
+		id := g.AddNode(&msg)
+		g.nodes[id].retry = n.Retry
+		if n.Compensation != nil {
+			n.Compensation.decodePayload()
+			n.Compensation.rebase(n.CompensationDeadlineInMs)
+			g.nodes[id].compensation = n.Compensation
+		}
+	}
+
+	if len(g.order) == 0 {
+		return g, nil
+	}
+	return g, g.Submit(opts...)
+}
+
+// MemoryCheckpointStore is a CheckpointStore kept in memory. It survives
+// reconnects but not process restarts, which makes it mostly useful for
+// tests and as a reference implementation.
+type MemoryCheckpointStore struct {
+	mu        sync.Mutex
+	graphs    map[string][]byte
+	completed map[string]map[string]bool
+}
+
+// NewMemoryCheckpointStore creates an empty MemoryCheckpointStore.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		graphs:    make(map[string][]byte),
+		completed: make(map[string]map[string]bool),
+	}
+}
+
+// SaveGraph implements CheckpointStore.
+func (s *MemoryCheckpointStore) SaveGraph(taskGraphID string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graphs[taskGraphID] = append([]byte(nil), data...)
+	return nil
+}
+
+// LoadGraph implements CheckpointStore.
+func (s *MemoryCheckpointStore) LoadGraph(taskGraphID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.graphs[taskGraphID]
+	if !ok {
+		return nil, fmt.Errorf("%w: no checkpoint for task graph %s", ErrNotFound, taskGraphID)
+	}
+	return data, nil
+}
+
+// MarkCompleted implements CheckpointStore.
+func (s *MemoryCheckpointStore) MarkCompleted(taskGraphID string, nodeIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.completed[taskGraphID]
+	if set == nil {
+		set = make(map[string]bool)
+		s.completed[taskGraphID] = set
+	}
+	for _, id := range nodeIDs {
+		set[id] = true
+	}
+	return nil
+}
+
+// Completed implements CheckpointStore.
+func (s *MemoryCheckpointStore) Completed(taskGraphID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.completed[taskGraphID]))
+	for id := range s.completed[taskGraphID] {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// FileCheckpointStore is a CheckpointStore writing to a directory. Each
+// graph is stored as <id>.graph.json, and its completed nodes are appended
+// to <id>.completed, one per line.
+type FileCheckpointStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore in dir, creating the
+// directory if needed.
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{Dir: dir}, nil
+}
+
+// SaveGraph implements CheckpointStore. The file is replaced atomically.
+func (s *FileCheckpointStore) SaveGraph(taskGraphID string, data []byte) error {
+	path := filepath.Join(s.Dir, taskGraphID+".graph.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadGraph implements CheckpointStore.
+func (s *FileCheckpointStore) LoadGraph(taskGraphID string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, taskGraphID+".graph.json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no checkpoint for task graph %s", ErrNotFound, taskGraphID)
+	}
+	return data, err
+}
+
+// MarkCompleted implements CheckpointStore. Nodes already recorded are not
+// appended again.
+func (s *FileCheckpointStore) MarkCompleted(taskGraphID string, nodeIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recorded, err := s.completed(taskGraphID)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(recorded))
+	for _, id := range recorded {
+		seen[id] = true
+	}
+	var fresh []string
+	for _, id := range nodeIDs {
+		if !seen[id] {
+			seen[id] = true
+			fresh = append(fresh, id)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.Dir, taskGraphID+".completed"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(strings.Join(fresh, "\n") + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Completed implements CheckpointStore.
+func (s *FileCheckpointStore) Completed(taskGraphID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.completed(taskGraphID)
+}
+
+// completed reads the recorded nodes. s.mu must be held.
+func (s *FileCheckpointStore) completed(taskGraphID string) ([]string, error) {
+	f, err := os.Open(filepath.Join(s.Dir, taskGraphID+".completed"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := scanner.Text(); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, scanner.Err()
+}
@@ -3,11 +3,15 @@ package aimesh
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,16 +19,148 @@ import (
 
 // Client is the AiMesh SDK client.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	apiKey     string
+	balancer    Balancer
+	httpClient  *http.Client
+	auth        authorizer
+	signer      RequestSigner
+	orgID       string
+	processor   PayloadProcessor
+	defaults    []CallOption
+	clock       clockSkew
+	reads       latencyWindow
+	flights     readGroup
+	coalesce    time.Duration
+	cache       readCache
+	cacheConfig *CacheConfig
+	etags       etagStore
+	correctSkew bool
+	info        serverInfoCache
+	replies     *replyRouter
+	checkpoints CheckpointStore
+	margin      time.Duration
+	timeout     time.Duration
+	maxRetries  int
+	budgetPoll  time.Duration
+	life        lifecycle
 }
 
 // ClientConfig configures the AiMesh client.
 type ClientConfig struct {
+	// BaseURL is the broker address, such as http://localhost:9000, or
+	// unix:///var/run/aimesh.sock to reach a broker sidecar over a unix
+	// domain socket.
 	BaseURL string
 	Timeout time.Duration
 	APIKey  string
+
+	// SecondaryAPIKey is tried when the broker rejects the primary key with
+	// a 401. To rotate keys without downtime, add the new key here, then
+	// promote it to APIKey and revoke the old one.
+	SecondaryAPIKey string
+
+	// KeyProvider supplies the primary key for each request instead of
+	// APIKey. A request refused with a 401 is retried once if the provider
+	// then returns a different key.
+	KeyProvider KeyProvider
+
+	// TokenSource authenticates requests with short-lived access tokens,
+	// such as OAuth2 or OIDC tokens, instead of API keys. Tokens are
+	// refreshed before they expire, and a request refused with a 401 is
+	// retried once with a fresh token.
+	TokenSource TokenSource
+
+	// Signer signs every request, for gateways that authenticate by
+	// signature, such as API Gateway with SigV4Signer. Its Authorization
+	// header replaces the API key or token.
+	Signer RequestSigner
+
+	// OrgID scopes every call to an organization of a broker shared by
+	// several tenants. It is sent in the X-AiMesh-Org header and stamped on
+	// outgoing messages. Individual calls can override it with WithOrgID.
+	OrgID string
+
+	// PayloadProcessor rewrites every outgoing message before it is sent,
+	// such as a Redactor masking personal data.
+	PayloadProcessor PayloadProcessor
+
+	// DefaultOptions apply to every call before the call's own options,
+	// such as WithUserAgent and WithDefaultHeaders to tag traffic by
+	// service.
+	DefaultOptions []CallOption
+
+	// CorrectClockSkew moves the Timestamp, DeadlineMs and DeliverAtMs of
+	// sent, published, broadcast and task graph messages onto the broker's
+	// clock, using the skew reported by ClockSkew, so that a host with a
+	// drifting clock does not send messages that expire early.
+	CorrectClockSkew bool
+
+	// BaseURLs lists several brokers in priority order. Requests go to the
+	// first available one and fail over to the next on connection errors
+	// and 503s; traffic returns to a broker once its health check passes
+	// again. BaseURL is ignored when BaseURLs is set.
+	BaseURLs []string
+
+	// FailbackInterval is how often brokers that failed are health-checked
+	// for recovery. Defaults to 10 seconds.
+	FailbackInterval time.Duration
+
+	// Balancer spreads requests over BaseURLs instead of the default
+	// priority failover. See NewRoundRobinBalancer and
+	// NewLeastLatencyBalancer.
+	Balancer Balancer
+
+	// Discovery refreshes the brokers at runtime, for example from a DNS
+	// SRV record. BaseURLs are used until the first lookup succeeds.
+	Discovery Discovery
+
+	// DiscoveryInterval is how often Discovery is polled. Defaults to 30
+	// seconds.
+	DiscoveryInterval time.Duration
+
+	// OnDiscoveryError is called when a Discovery lookup fails; the client
+	// keeps using the last known brokers.
+	OnDiscoveryError func(error)
+
+	// MaxRetries is how many times a call is retried after a transient
+	// failure. Individual calls can override it with WithRetries.
+	MaxRetries int
+
+	// DeadlineMargin is subtracted from a context deadline when
+	// SendMessageContext derives the message deadline from it, leaving
+	// time for the reply to travel back before the caller gives up.
+	DeadlineMargin time.Duration
+
+	// Checkpoints records submitted task graphs and their completed nodes
+	// so that ResumeTaskGraph can pick up after an orchestrator crash.
+	Checkpoints CheckpointStore
+
+	// CoalesceWindow is how long the result of a GET, such as GetBudget or
+	// HealthCheck, is shared with identical calls made after it completes.
+	// Identical GETs made while one is in flight always share its result,
+	// so that many goroutines reading the same budget send one request.
+	// Defaults to 10ms; a negative value disables coalescing.
+	CoalesceWindow time.Duration
+
+	// Cache enables client-side caching of GetBudget, GetEndpoint and
+	// ListEndpoints. Caching is off when it is nil.
+	Cache *CacheConfig
+
+	// BudgetPollInterval is how often budget alerts poll utilization.
+	// Defaults to 15 seconds.
+	BudgetPollInterval time.Duration
+
+	// Transport tunes connection pooling and low-level timeouts.
+	Transport *TransportConfig
+
+	// TLS configures broker certificate verification and client
+	// certificates for mutual TLS.
+	TLS *TLSConfig
+
+	// HTTPClient replaces the client's HTTP client entirely, for callers
+	// that bring their own transport. Transport and TLS are ignored when it
+	// is set, and its Timeout should be zero so that Timeout and
+	// WithTimeout apply.
+	HTTPClient *http.Client
 }
 
 // NewClient creates a new AiMesh client.
@@ -32,17 +168,59 @@ func NewClient(config ClientConfig) *Client {
 	if config.BaseURL == "" {
 		config.BaseURL = "http://localhost:9000"
 	}
+	if len(config.BaseURLs) == 0 {
+		config.BaseURLs = []string{config.BaseURL}
+	}
 	if config.Timeout == 0 {
 		config.Timeout = 30 * time.Second
 	}
+	if config.CoalesceWindow == 0 {
+		config.CoalesceWindow = defaultCoalesceWindow
+	}
+	sockets := make(unixSockets)
+	urls := make([]string, len(config.BaseURLs))
+	for i, u := range config.BaseURLs {
+		urls[i] = sockets.rewrite(u)
+	}
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Transport: newTransport(config.Transport, tlsClientConfig(config.TLS), sockets)}
+	}
 
-	return &Client{
-		baseURL: config.BaseURL,
-		httpClient: &http.Client{
-			Timeout: config.Timeout,
-		},
-		apiKey: config.APIKey,
+	c := &Client{
+		httpClient:  httpClient,
+		replies:     newReplyRouter(),
+		checkpoints: config.Checkpoints,
+		margin:      config.DeadlineMargin,
+		timeout:     config.Timeout,
+		maxRetries:  config.MaxRetries,
+		budgetPoll:  config.BudgetPollInterval,
+		coalesce:    config.CoalesceWindow,
+		cacheConfig: config.Cache,
+		signer:      config.Signer,
+		orgID:       config.OrgID,
+		processor:   config.PayloadProcessor,
+		defaults:    config.DefaultOptions,
+		correctSkew: config.CorrectClockSkew,
 	}
+	if config.TokenSource != nil {
+		c.auth = &tokenAuth{source: config.TokenSource}
+	} else {
+		keys := config.KeyProvider
+		if keys == nil {
+			keys = StaticKey(config.APIKey)
+		}
+		c.auth = &keyRing{provider: keys, secondary: config.SecondaryAPIKey}
+	}
+	c.balancer = config.Balancer
+	if c.balancer == nil {
+		c.balancer = newFailover(c, config.FailbackInterval)
+	}
+	c.balancer.Update(urls)
+	if config.Discovery != nil {
+		c.startDiscovery(config.Discovery, config.DiscoveryInterval, config.OnDiscoveryError)
+	}
+	return c
 }
 
 // Message represents an AI message.
@@ -61,6 +239,65 @@ type Message struct {
 	TraceID            string            `json:"trace_id"`
 	Metadata           map[string]string `json:"metadata"`
 	Timestamp          int64             `json:"timestamp"`
+	LastFailure        *FailureReason    `json:"last_failure,omitempty"`
+	DeliveryCount      int               `json:"delivery_count,omitempty"`
+	DeliverAtMs        int64             `json:"deliver_at_ms,omitempty"`
+	TTLMs              int64             `json:"ttl_ms,omitempty"`
+	OrderingKey        string            `json:"ordering_key,omitempty"`
+	ReplyTo            string            `json:"reply_to,omitempty"`
+	CorrelationID      string            `json:"correlation_id,omitempty"`
+	ConversationID     string            `json:"conversation_id,omitempty"`
+	ParentMessageID    string            `json:"parent_message_id,omitempty"`
+	Topic              string            `json:"topic,omitempty"`
+	Partition          int               `json:"partition,omitempty"`
+	Requirements       *Requirements     `json:"requirements,omitempty"`
+	ShadowTo           []string          `json:"shadow_to,omitempty"`
+	AffinityKey        string            `json:"affinity_key,omitempty"`
+	TargetEndpoint     string            `json:"target_endpoint,omitempty"`
+	BudgetPoolID       string            `json:"budget_pool_id,omitempty"`
+	OrgID              string            `json:"org_id,omitempty"`
+}
+
+// Reply creates a message answering parent. It is addressed to the parent's
+// reply-to agent, and it inherits the parent's conversation (the parent
+// itself starts one if it has none), correlation ID, trace, task graph,
+// affinity key and organization.
+func Reply(parent *Message, payload []byte) *Message {
+	msg := NewMessage(parent.ReplyTo, payload)
+	msg.ConversationID = parent.ConversationID
+	if msg.ConversationID == "" {
+		msg.ConversationID = parent.MessageID
+	}
+	msg.ParentMessageID = parent.MessageID
+	msg.CorrelationID = parent.CorrelationID
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = parent.MessageID
+	}
+	msg.TraceID = parent.TraceID
+	msg.TaskGraphID = parent.TaskGraphID
+	msg.AffinityKey = parent.AffinityKey
+	msg.OrgID = parent.OrgID
+	return msg
+}
+
+// Priority is a message priority from MinPriority to MaxPriority; higher
+// values are delivered first.
+type Priority int
+
+// Priority levels. Use these instead of ad-hoc values so that producers
+// across teams share one scale.
+const (
+	MinPriority      Priority = 0
+	PriorityLow      Priority = 25
+	PriorityNormal   Priority = 50
+	PriorityHigh     Priority = 75
+	PriorityCritical Priority = 100
+	MaxPriority      Priority = 100
+)
+
+// Valid reports whether p is within MinPriority and MaxPriority.
+func (p Priority) Valid() bool {
+	return p >= MinPriority && p <= MaxPriority
 }
 
 // NewMessage creates a new message.
@@ -70,15 +307,143 @@ func NewMessage(agentID string, payload []byte) *Message {
 		MessageID:    uuid.New().String(),
 		Payload:      payload,
 		PayloadHex:   hex.EncodeToString(payload),
-		BudgetTokens: 1000,
+		BudgetTokens: DefaultBudgetTokens,
 		DeadlineMs:   time.Now().UnixMilli() + 60000,
-		Priority:     50,
+		Priority:     int(PriorityNormal),
 		Dependencies: []string{},
 		Metadata:     make(map[string]string),
 		Timestamp:    time.Now().UnixNano(),
 	}
 }
 
+// WithPriority sets the message priority.
+func (m *Message) WithPriority(p Priority) *Message {
+	m.Priority = int(p)
+	return m
+}
+
+// WithOrderingKey places the message in a FIFO group. Messages sharing an
+// ordering key are delivered one at a time in send order; messages with
+// different keys are still processed in parallel.
+func (m *Message) WithOrderingKey(key string) *Message {
+	m.OrderingKey = key
+	return m
+}
+
+// WithAffinityKey pins the message to the endpoint that served earlier
+// messages with the same key, as long as that endpoint stays healthy, so that
+// conversational agents reuse the endpoint's KV cache. Replies inherit the
+// key of the message they answer.
+func (m *Message) WithAffinityKey(key string) *Message {
+	m.AffinityKey = key
+	return m
+}
+
+// WithDeliverAt schedules the message for delivery at t instead of
+// immediately.
+func (m *Message) WithDeliverAt(t time.Time) *Message {
+	m.DeliverAtMs = t.UnixMilli()
+	return m
+}
+
+// WithDelay schedules the message for delivery d from now.
+func (m *Message) WithDelay(d time.Duration) *Message {
+	return m.WithDeliverAt(time.Now().Add(d))
+}
+
+// ExpiresIn sets the message time-to-live. Unlike DeadlineMs, which bounds
+// processing, an expired message is discarded without being delivered and
+// acknowledged with StatusExpired.
+func (m *Message) ExpiresIn(d time.Duration) *Message {
+	m.TTLMs = d.Milliseconds()
+	return m
+}
+
+// ExpiresAt returns when the message expires, or the zero time if it has no
+// TTL or no Timestamp to count the TTL from.
+func (m *Message) ExpiresAt() time.Time {
+	if m.TTLMs <= 0 || m.Timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, m.Timestamp).Add(time.Duration(m.TTLMs) * time.Millisecond)
+}
+
+// IsExpired reports whether the message TTL has elapsed.
+func (m *Message) IsExpired() bool {
+	exp := m.ExpiresAt()
+	return !exp.IsZero() && time.Now().After(exp)
+}
+
+// deadlineIn returns how many milliseconds after its timestamp, or after now
+// if it has none, the message's deadline falls, or zero if it has none.
+func deadlineIn(m *Message) int64 {
+	if m.DeadlineMs == 0 {
+		return 0
+	}
+	from := time.Now().UnixMilli()
+	if m.Timestamp != 0 {
+		from = time.Unix(0, m.Timestamp).UnixMilli()
+	}
+	return m.DeadlineMs - from
+}
+
+// rebase stamps the message with the current time and moves its deadline to
+// deadlineInMs after it. A zero deadlineInMs keeps the deadline's original
+// distance from the timestamp.
+func (m *Message) rebase(deadlineInMs int64) {
+	if deadlineInMs == 0 {
+		deadlineInMs = deadlineIn(m)
+	}
+	now := time.Now()
+	m.Timestamp = now.UnixNano()
+	if m.DeadlineMs != 0 {
+		m.DeadlineMs = now.UnixMilli() + deadlineInMs
+	}
+}
+
+// Validate checks the message for errors the broker would reject.
+func (m *Message) Validate() error {
+	if m.AgentID == "" {
+		return fmt.Errorf("%w: agent_id is required", ErrValidation)
+	}
+	if !Priority(m.Priority).Valid() {
+		return fmt.Errorf("%w: priority %d outside %d-%d",
+			ErrValidation, m.Priority, MinPriority, MaxPriority)
+	}
+	if m.DeliverAtMs != 0 && m.DeadlineMs != 0 && m.DeliverAtMs >= m.DeadlineMs {
+		return fmt.Errorf("%w: deliver_at_ms %d is not before deadline_ms %d",
+			ErrValidation, m.DeliverAtMs, m.DeadlineMs)
+	}
+	if m.TTLMs < 0 {
+		return fmt.Errorf("%w: ttl_ms must not be negative", ErrValidation)
+	}
+	if m.TTLMs > 0 && m.Timestamp == 0 {
+		return fmt.Errorf("%w: ttl_ms needs a timestamp to count from", ErrValidation)
+	}
+	if exp := m.ExpiresAt(); !exp.IsZero() && m.DeliverAtMs >= exp.UnixMilli() {
+		return fmt.Errorf("%w: deliver_at_ms %d is not before expiry %d",
+			ErrValidation, m.DeliverAtMs, exp.UnixMilli())
+	}
+	if m.Requirements != nil {
+		return m.Requirements.Validate()
+	}
+	return nil
+}
+
+// decodePayload fills Payload from the hex-encoded wire representation.
+func (m *Message) decodePayload() {
+	if m.PayloadHex != "" {
+		m.Payload, _ = hex.DecodeString(m.PayloadHex)
+	}
+}
+
+// Acknowledgment statuses.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusExpired = "expired"
+)
+
 // Acknowledgment represents a processed message acknowledgment.
 type Acknowledgment struct {
 	OriginalMessageID   string  `json:"original_message_id"`
@@ -92,7 +457,19 @@ type Acknowledgment struct {
 
 // IsSuccess returns true if the message was processed successfully.
 func (a *Acknowledgment) IsSuccess() bool {
-	return a.Status == "success"
+	return a.Status == StatusSuccess
+}
+
+// IsExpired returns true if the message expired before it was processed.
+func (a *Acknowledgment) IsExpired() bool {
+	return a.Status == StatusExpired
+}
+
+// encodeResult fills the hex-encoded wire representation from Result.
+func (a *Acknowledgment) encodeResult() {
+	if a.ResultHex == "" && len(a.Result) > 0 {
+		a.ResultHex = hex.EncodeToString(a.Result)
+	}
 }
 
 // EndpointMetrics represents AI endpoint metrics.
@@ -104,6 +481,9 @@ type EndpointMetrics struct {
 	LatencyP99Ms    float64 `json:"latency_p99_ms"`
 	ErrorRate       float64 `json:"error_rate"`
 	HealthStatus    string  `json:"health_status"`
+
+	Capabilities *EndpointCapabilities `json:"capabilities,omitempty"`
+	Labels       map[string]string     `json:"labels,omitempty"`
 }
 
 // BudgetInfo represents agent budget information.
@@ -132,38 +512,256 @@ type HealthStatus struct {
 	EndpointsTotal   int    `json:"endpoints_total"`
 }
 
+// ListOptions controls pagination for list operations.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+}
+
+// values encodes the pagination options as query parameters.
+func (o *ListOptions) values() url.Values {
+	v := url.Values{}
+	if o == nil {
+		return v
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	}
+	return v
+}
+
+// withQuery appends encoded query parameters to a request path.
+func withQuery(path string, v url.Values) string {
+	if len(v) == 0 {
+		return path
+	}
+	if strings.Contains(path, "?") {
+		return path + "&" + v.Encode()
+	}
+	return path + "?" + v.Encode()
+}
+
+// Version is the SDK version, sent to the broker in the X-AiMesh-SDK header.
+const Version = "0.1.0"
+
 // Errors
 var (
 	ErrConnection     = fmt.Errorf("connection error")
 	ErrRateLimit      = fmt.Errorf("rate limit exceeded")
 	ErrBudgetExceeded = fmt.Errorf("budget exceeded")
 	ErrValidation     = fmt.Errorf("validation error")
+	ErrNotFound       = fmt.Errorf("not found")
+	ErrConflict       = fmt.Errorf("conflict")
+	ErrTimeout        = fmt.Errorf("timeout")
+	ErrUnauthorized   = fmt.Errorf("unauthorized")
 )
 
-func (c *Client) request(method, path string, body interface{}) ([]byte, error) {
-	var reqBody io.Reader
+// APIError is returned when the broker responds with an error status. It
+// unwraps to the matching sentinel error, so errors.Is(err, ErrRateLimit)
+// and friends keep working.
+type APIError struct {
+	StatusCode int
+	Body       []byte
+	sentinel   error
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode, Body: body}
+	switch statusCode {
+	case 429:
+		e.sentinel = ErrRateLimit
+	case 402:
+		e.sentinel = ErrBudgetExceeded
+	case 400:
+		e.sentinel = ErrValidation
+	case 401:
+		e.sentinel = ErrUnauthorized
+	case 404:
+		e.sentinel = ErrNotFound
+	case 409:
+		e.sentinel = ErrConflict
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.StatusCode == 429 || e.StatusCode == 402:
+		return e.sentinel.Error()
+	case e.sentinel != nil:
+		return fmt.Sprintf("%v: %s", e.sentinel, string(e.Body))
+	default:
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, string(e.Body))
+	}
+}
+
+// Unwrap returns the sentinel error matching the status code, if any.
+func (e *APIError) Unwrap() error {
+	return e.sentinel
+}
+
+func (c *Client) request(method, path string, body interface{}, opts ...CallOption) ([]byte, error) {
+	return c.requestContext(context.Background(), method, path, body, opts...)
+}
+
+func (c *Client) requestContext(ctx context.Context, method, path string, body interface{}, opts ...CallOption) ([]byte, error) {
+	var data []byte
 	if body != nil {
-		data, err := json.Marshal(body)
+		var err error
+		data, err = json.Marshal(body)
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	o := c.callOptions(opts)
+	if o.err != nil {
+		return nil, o.err
+	}
+	path = withQuery(path, o.query)
+	if method == "GET" && o.cacheTTL > 0 {
+		key := readKey(path, o)
+		if body, ok := c.cache.get(key); ok && !o.noCache {
+			return body, nil
+		}
+		body, err := c.read(ctx, path, o)
+		if err == nil {
+			c.cache.put(key, body, o.cacheTTL)
+		}
+		return body, err
+	}
+	if method == "GET" {
+		return c.read(ctx, path, o)
+	}
+	return c.attempts(ctx, method, path, data, o)
+}
+
+// read makes a GET, sharing the result with identical concurrent reads.
+func (c *Client) read(ctx context.Context, path string, o *callOptions) ([]byte, error) {
+	if !o.poll && !o.noCache && c.coalesce >= 0 {
+		// The shared request must not fail because the caller that happened
+		// to start it gave up; each caller still stops waiting on its own ctx.
+		shared := context.WithoutCancel(ctx)
+		return c.flights.do(ctx, readKey(path, o), c.coalesce, func() ([]byte, error) {
+			return c.attempts(shared, "GET", path, nil, o)
+		})
+	}
+	return c.attempts(ctx, "GET", path, nil, o)
+}
+
+// attempts makes a request, retrying it as o allows.
+func (c *Client) attempts(ctx context.Context, method, path string, data []byte, o *callOptions) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		var respBody []byte
+		var err error
+		if o.hedge && method == "GET" && !o.poll {
+			respBody, err = c.sendHedged(ctx, method, path, data, o)
+		} else {
+			respBody, err = c.send(ctx, method, path, data, o)
+		}
+		if err == nil || attempt >= o.retries || !retryable(err) || !resendable(method, err, o) {
+			return respBody, err
+		}
+		sleepContext(ctx, retryBackoff(attempt))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send makes one attempt of a request, moving on to the next broker while
+// brokers are unavailable.
+func (c *Client) send(ctx context.Context, method, path string, data []byte, o *callOptions) ([]byte, error) {
+	return c.sendTo(ctx, c.balancer.Pick(), method, path, data, o)
+}
+
+// sendTo is send over the given brokers, in order.
+func (c *Client) sendTo(ctx context.Context, bases []string, method, path string, data []byte, o *callOptions) ([]byte, error) {
+	var err error
+	for _, base := range bases {
+		start := time.Now()
+		var respBody []byte
+		respBody, err = c.do(ctx, base, method, path, data, o)
+		latency := time.Since(start)
+		if o.poll {
+			latency = 0
+		} else if err == nil && method == "GET" {
+			c.reads.add(latency)
+		}
+		// A call the caller gave up on, or a hedge that lost, says nothing
+		// about the broker.
+		if ctx.Err() == nil {
+			c.balancer.Report(base, latency, err)
+		}
+		if !failsOver(err) || ctx.Err() != nil || !resendable(method, err, o) {
+			return respBody, err
+		}
+	}
+	return nil, err
+}
+
+// newRequest builds a broker request with the client's standard headers and
+// the auth Authorization header.
+func (c *Client) newRequest(ctx context.Context, url, method string, data []byte, header http.Header, auth string) (*http.Request, error) {
+	var reqBody io.Reader
+	if data != nil {
 		reqBody = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	if c.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("User-Agent", "aimesh-go/"+Version)
+	req.Header.Set("X-AiMesh-SDK", "go/"+Version)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.signer != nil {
+		if err := c.signer.SignRequest(req, data); err != nil {
+			return nil, fmt.Errorf("sign request: %w", err)
+		}
+	}
+	return req, nil
+}
+
+func (c *Client) do(parent context.Context, base, method, path string, data []byte, o *callOptions) ([]byte, error) {
+	ctx := parent
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, o.timeout)
+		defer cancel()
 	}
 
-	resp, err := c.httpClient.Do(req)
+	auth, err := c.credentials(ctx, o)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
+		return nil, err
+	}
+	header := o.header
+	var key string
+	var stored *etagEntry
+	if o.conditional && method == "GET" {
+		key = base + readKey(path, o)
+		header, stored = c.revalidate(key, header)
+	}
+	resp, err := c.roundTrip(ctx, base+path, method, data, header, auth)
+	if err != nil {
+		if ctxErr := parent.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %s %s after %v", ErrTimeout, method, path, o.timeout)
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -172,29 +770,71 @@ func (c *Client) request(method, path string, body interface{}) ([]byte, error)
 		return nil, err
 	}
 
-	switch resp.StatusCode {
-	case 429:
-		return nil, ErrRateLimit
-	case 402:
-		return nil, ErrBudgetExceeded
-	case 400:
-		return nil, fmt.Errorf("%w: %s", ErrValidation, string(respBody))
-	}
-
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+	if stored != nil && resp.StatusCode == http.StatusNotModified {
+		return stored.body, nil
+	}
+	if etag := resp.Header.Get("ETag"); key != "" && etag != "" {
+		c.etags.put(key, etag, respBody)
 	}
 
 	return respBody, nil
 }
 
 // SendMessage sends a message for processing.
-func (c *Client) SendMessage(msg *Message) (*Acknowledgment, error) {
-	data, err := c.request("POST", "/messages", msg)
+func (c *Client) SendMessage(msg *Message, opts ...CallOption) (*Acknowledgment, error) {
+	return c.SendMessageContext(context.Background(), msg, opts...)
+}
+
+// SendMessageContext sends a message for processing, bounded by ctx. If ctx
+// has a deadline, the message deadline is lowered to it, minus the
+// configured DeadlineMargin, so downstream agents never start work the
+// caller has already abandoned.
+func (c *Client) SendMessageContext(ctx context.Context, msg *Message, opts ...CallOption) (*Acknowledgment, error) {
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	defer c.end()
+
+	if err := c.prepare(ctx, msg, opts); err != nil {
+		return nil, err
+	}
+
+	opts = append([]CallOption{idempotent(msg.DedupContext != "")}, opts...)
+	data, err := c.requestContext(ctx, "POST", "/messages", c.toServerClock(msg), opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	return decodeAck(data)
+}
+
+// prepare readies msg for sending: it lowers the deadline to ctx's,
+// validates the message, stamps the organization and runs the payload
+// processor.
+func (c *Client) prepare(ctx context.Context, msg *Message, opts []CallOption) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := deadline.Add(-c.margin).UnixMilli()
+		if ms <= time.Now().UnixMilli() {
+			return fmt.Errorf("%w: no time left to process message %s", context.DeadlineExceeded, msg.MessageID)
+		}
+		if msg.DeadlineMs == 0 || ms < msg.DeadlineMs {
+			msg.DeadlineMs = ms
+		}
+	}
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	if msg.OrgID == "" {
+		msg.OrgID = c.callOptions(opts).orgID
+	}
+	return c.process(msg)
+}
+
+// decodeAck parses an acknowledgment response body.
+func decodeAck(data []byte) (*Acknowledgment, error) {
 	var ack Acknowledgment
 	if err := json.Unmarshal(data, &ack); err != nil {
 		return nil, err
@@ -207,15 +847,22 @@ func (c *Client) SendMessage(msg *Message) (*Acknowledgment, error) {
 	return &ack, nil
 }
 
-// RegisterEndpoint registers an AI endpoint.
-func (c *Client) RegisterEndpoint(metrics *EndpointMetrics) error {
-	_, err := c.request("POST", "/endpoints", metrics)
+// RegisterEndpoint registers an AI endpoint. Close removes it again.
+func (c *Client) RegisterEndpoint(metrics *EndpointMetrics, opts ...CallOption) error {
+	_, err := c.request("POST", "/endpoints", metrics, opts...)
+	if err == nil {
+		c.registered(metrics.EndpointID, false)
+		c.InvalidateEndpoint(metrics.EndpointID)
+	}
 	return err
 }
 
-// ListEndpoints lists all registered endpoints.
-func (c *Client) ListEndpoints() ([]EndpointMetrics, error) {
-	data, err := c.request("GET", "/endpoints", nil)
+// ListEndpoints lists all registered endpoints, or those matching
+// WithSelector. Results are cached for ClientConfig.Cache.EndpointListTTL,
+// and later calls are sent with If-None-Match so that an unchanged registry
+// is not transferred again.
+func (c *Client) ListEndpoints(opts ...CallOption) ([]EndpointMetrics, error) {
+	data, err := c.request("GET", "/endpoints", nil, append([]CallOption{cached(c.endpointListTTL()), conditional()}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -231,23 +878,29 @@ func (c *Client) ListEndpoints() ([]EndpointMetrics, error) {
 }
 
 // RemoveEndpoint removes an endpoint.
-func (c *Client) RemoveEndpoint(endpointID string) error {
-	_, err := c.request("DELETE", "/endpoints/"+endpointID, nil)
+func (c *Client) RemoveEndpoint(endpointID string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/endpoints/"+endpointID, nil, opts...)
+	if err == nil {
+		c.registered(endpointID, true)
+		c.InvalidateEndpoint(endpointID)
+	}
 	return err
 }
 
 // SetBudget sets token budget for an agent.
-func (c *Client) SetBudget(agentID string, tokens float64) error {
+func (c *Client) SetBudget(agentID string, tokens float64, opts ...CallOption) error {
 	_, err := c.request("POST", "/budgets", map[string]interface{}{
 		"agent_id": agentID,
 		"tokens":   tokens,
-	})
+	}, opts...)
+	c.InvalidateBudget(agentID)
 	return err
 }
 
-// GetBudget gets budget info for an agent.
-func (c *Client) GetBudget(agentID string) (*BudgetInfo, error) {
-	data, err := c.request("GET", "/budgets/"+agentID, nil)
+// GetBudget gets budget info for an agent. Results are cached for
+// ClientConfig.Cache.BudgetTTL.
+func (c *Client) GetBudget(agentID string, opts ...CallOption) (*BudgetInfo, error) {
+	data, err := c.request("GET", "/budgets/"+agentID, nil, append([]CallOption{cached(c.budgetTTL())}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -261,14 +914,15 @@ func (c *Client) GetBudget(agentID string) (*BudgetInfo, error) {
 }
 
 // ResetBudget resets an agent's budget.
-func (c *Client) ResetBudget(agentID string) error {
-	_, err := c.request("POST", "/budgets/"+agentID+"/reset", nil)
+func (c *Client) ResetBudget(agentID string, opts ...CallOption) error {
+	_, err := c.request("POST", "/budgets/"+agentID+"/reset", nil, opts...)
+	c.InvalidateBudget(agentID)
 	return err
 }
 
 // HealthCheck checks server health.
-func (c *Client) HealthCheck() (*HealthStatus, error) {
-	data, err := c.request("GET", "/health", nil)
+func (c *Client) HealthCheck(opts ...CallOption) (*HealthStatus, error) {
+	data, err := c.request("GET", "/health", nil, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -282,8 +936,8 @@ func (c *Client) HealthCheck() (*HealthStatus, error) {
 }
 
 // GetMetrics gets Prometheus metrics.
-func (c *Client) GetMetrics() (string, error) {
-	data, err := c.request("GET", "/metrics", nil)
+func (c *Client) GetMetrics(opts ...CallOption) (string, error) {
+	data, err := c.request("GET", "/metrics", nil, opts...)
 	if err != nil {
 		return "", err
 	}
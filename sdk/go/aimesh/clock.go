@@ -0,0 +1,114 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockSkew tracks how far the broker's clock is ahead of the local one.
+type clockSkew struct {
+	mu     sync.Mutex
+	offset time.Duration
+	// precise is set once the offset was measured with MeasureClockSkew,
+	// whose millisecond timestamps beat the one second resolution of Date
+	// headers.
+	precise bool
+}
+
+// observeDate updates the offset from a response's Date header. sent and
+// received bracket the round trip; the broker is assumed to have stamped
+// the response halfway through. Because Date is truncated to the second,
+// skew within a second reads as none, and the estimate only moves when it
+// is off by more than that.
+func (s *clockSkew) observeDate(sent, received time.Time, date string) {
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	offset := server.Add(500 * time.Millisecond).Sub(local)
+	if offset >= -time.Second && offset <= time.Second {
+		offset = 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if diff := offset - s.offset; !s.precise || diff > time.Second || diff < -time.Second {
+		s.offset, s.precise = offset, false
+	}
+}
+
+func (s *clockSkew) set(offset time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset, s.precise = offset, true
+}
+
+func (s *clockSkew) get() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// ClockSkew returns how far the broker's clock is ahead of the local clock,
+// negative if it is behind. It is estimated from the Date header of every
+// response, to within a second, and from MeasureClockSkew. It is zero until
+// the first response.
+func (c *Client) ClockSkew() time.Duration {
+	return c.clock.get()
+}
+
+// MeasureClockSkew asks the broker for its time, to the millisecond, and
+// returns and records how far its clock is ahead of the local one.
+func (c *Client) MeasureClockSkew(opts ...CallOption) (time.Duration, error) {
+	sent := time.Now()
+	data, err := c.request("GET", "/time", nil, opts...)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	var resp struct {
+		NowMs int64 `json:"now_ms"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, err
+	}
+
+	offset := time.UnixMilli(resp.NowMs).Sub(sent.Add(received.Sub(sent) / 2))
+	c.clock.set(offset)
+	return offset, nil
+}
+
+// serverNow returns the current time on the broker's clock when
+// ClientConfig.CorrectClockSkew is set, and the local time otherwise.
+func (c *Client) serverNow() time.Time {
+	if !c.correctSkew {
+		return time.Now()
+	}
+	return time.Now().Add(c.clock.get())
+}
+
+// toServerClock returns msg with its timestamp and deadlines moved onto the
+// broker's clock when ClientConfig.CorrectClockSkew is set. msg itself is
+// left unchanged so that it can be sent again.
+func (c *Client) toServerClock(msg *Message) *Message {
+	if !c.correctSkew {
+		return msg
+	}
+	offset := c.clock.get()
+	if offset == 0 {
+		return msg
+	}
+	shifted := *msg
+	shifted.Timestamp += offset.Nanoseconds()
+	if shifted.DeadlineMs != 0 {
+		shifted.DeadlineMs += offset.Milliseconds()
+	}
+	if shifted.DeliverAtMs != 0 {
+		shifted.DeliverAtMs += offset.Milliseconds()
+	}
+	return &shifted
+}
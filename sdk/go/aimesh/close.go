@@ -0,0 +1,203 @@
+package aimesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrClosed is returned by sends on a client that has been closed.
+var ErrClosed = errors.New("client closed")
+
+// drainer is a background consumer, such as a running Worker or an open
+// Subscription, that Close stops and waits for. drain gives up without an
+// error when ctx is done.
+type drainer interface {
+	drain(ctx context.Context) error
+}
+
+// lifecycle tracks what Close has to wait for or undo.
+type lifecycle struct {
+	mu        sync.Mutex
+	closed    bool
+	inFlight  sync.WaitGroup
+	drainers  map[drainer]struct{}
+	endpoints map[string]struct{}
+}
+
+// begin registers an in-flight send. It fails with ErrClosed once Close has
+// been called; otherwise the caller must call c.end when the send is done.
+func (c *Client) begin() error {
+	c.life.mu.Lock()
+	defer c.life.mu.Unlock()
+	if c.life.closed {
+		return ErrClosed
+	}
+	c.life.inFlight.Add(1)
+	return nil
+}
+
+func (c *Client) end() {
+	c.life.inFlight.Done()
+}
+
+// track registers d to be drained by Close. It fails with ErrClosed once
+// Close has been called.
+func (c *Client) track(d drainer) error {
+	c.life.mu.Lock()
+	defer c.life.mu.Unlock()
+	if c.life.closed {
+		return ErrClosed
+	}
+	if c.life.drainers == nil {
+		c.life.drainers = make(map[drainer]struct{})
+	}
+	c.life.drainers[d] = struct{}{}
+	return nil
+}
+
+func (c *Client) untrack(d drainer) {
+	c.life.mu.Lock()
+	delete(c.life.drainers, d)
+	c.life.mu.Unlock()
+}
+
+// registered records an endpoint registered through this client, or forgets
+// it when removed is true.
+func (c *Client) registered(endpointID string, removed bool) {
+	c.life.mu.Lock()
+	defer c.life.mu.Unlock()
+	if removed {
+		delete(c.life.endpoints, endpointID)
+		return
+	}
+	if c.life.endpoints == nil {
+		c.life.endpoints = make(map[string]struct{})
+	}
+	c.life.endpoints[endpointID] = struct{}{}
+}
+
+// Close shuts the client down gracefully. New sends fail with ErrClosed;
+// running workers, subscriptions and consumer group memberships are stopped
+// once their in-progress messages are settled; in-flight sends are waited
+// for; and endpoints registered through this client are removed. If ctx
+// expires first, Close stops waiting, still removes the endpoints and
+// returns an error wrapping ctx.Err(). Calling Close again has no effect.
+func (c *Client) Close(ctx context.Context) error {
+	c.life.mu.Lock()
+	if c.life.closed {
+		c.life.mu.Unlock()
+		return nil
+	}
+	c.life.closed = true
+	drainers := make([]drainer, 0, len(c.life.drainers))
+	for d := range c.life.drainers {
+		drainers = append(drainers, d)
+	}
+	c.life.mu.Unlock()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, d := range drainers {
+		wg.Add(1)
+		go func(d drainer) {
+			defer wg.Done()
+			if err := d.drain(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	idle := make(chan struct{})
+	go func() {
+		c.life.inFlight.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.DeregisterEndpoints(context.WithoutCancel(ctx)); err != nil {
+		errs = append(errs, err)
+	}
+
+	if f, ok := c.balancer.(*failover); ok {
+		f.close()
+	}
+	return errors.Join(errs...)
+}
+
+// DeregisterEndpoints removes every endpoint registered through this client
+// that has not been removed yet. Close calls it; it is useful on its own to
+// stop traffic to a pod before it finishes its remaining work.
+func (c *Client) DeregisterEndpoints(ctx context.Context) error {
+	c.life.mu.Lock()
+	endpoints := make([]string, 0, len(c.life.endpoints))
+	for id := range c.life.endpoints {
+		endpoints = append(endpoints, id)
+	}
+	c.life.mu.Unlock()
+
+	var errs []error
+	for _, id := range endpoints {
+		_, err := c.requestContext(ctx, "DELETE", "/endpoints/"+id, nil)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("deregistering endpoint %s: %w", id, err))
+			continue
+		}
+		c.registered(id, true)
+		c.InvalidateEndpoint(id)
+	}
+	return errors.Join(errs...)
+}
+
+// CloseOnSignal closes the client with a timeout when the process receives
+// one of signals, SIGTERM and SIGINT by default, so that its endpoints are
+// deregistered before the pod goes away. The result of Close is delivered
+// on the returned channel, after which the caller typically exits. Call stop
+// to stop listening for the signals.
+func (c *Client) CloseOnSignal(timeout time.Duration, signals ...os.Signal) (closed <-chan error, stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, signals...)
+
+	result := make(chan error, 1)
+	quit := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(sig)
+			close(quit)
+		})
+	}
+
+	go func() {
+		select {
+		case <-sig:
+		case <-quit:
+			return
+		}
+		stop()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		result <- c.Close(ctx)
+	}()
+	return result, stop
+}
@@ -0,0 +1,116 @@
+package aimesh
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCoalesceWindow is how long a read's result is shared after it
+// completes unless ClientConfig.CoalesceWindow says otherwise.
+const defaultCoalesceWindow = 10 * time.Millisecond
+
+// flight is a read in progress, or recently completed, whose result is
+// shared by every identical read.
+type flight struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// readGroup collapses identical concurrent reads into one request.
+type readGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do returns the result of fn, or of the identical call already in flight
+// under key. fn runs in its own goroutine so that every caller, including
+// the one that started it, can stop waiting when its ctx is done. Completed
+// results stay shared for window.
+func (g *readGroup) do(ctx context.Context, key string, window time.Duration, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	if !ok {
+		if g.flights == nil {
+			g.flights = make(map[string]*flight)
+		}
+		f = &flight{done: make(chan struct{})}
+		g.flights[key] = f
+	}
+	g.mu.Unlock()
+
+	if !ok {
+		go func() {
+			f.body, f.err = fn()
+			close(f.done)
+			if window > 0 {
+				time.AfterFunc(window, func() { g.forget(key, f) })
+			} else {
+				g.forget(key, f)
+			}
+		}()
+	}
+
+	select {
+	case <-f.done:
+		return f.body, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (g *readGroup) forget(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// forgetPath stops sharing the results of reads of path, whatever their
+// query string or headers, so that reads after a change see it.
+func (g *readGroup) forgetPath(path string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k := range g.flights {
+		if keyPath(k) == path {
+			delete(g.flights, k)
+		}
+	}
+}
+
+// forgetAll stops sharing the results of every read.
+func (g *readGroup) forgetAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flights = nil
+}
+
+// keyPath returns the path of a readKey, without its query string.
+func keyPath(key string) string {
+	if i := strings.IndexAny(key, "?\n"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// readKey identifies a read by its path and the headers it is sent with, so
+// that calls made with different credentials or organizations are never
+// collapsed.
+func readKey(path string, o *callOptions) string {
+	keys := make([]string, 0, len(o.header))
+	for k := range o.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(path)
+	for _, k := range keys {
+		b.WriteString("\n" + k + ": " + strings.Join(o.header[k], ", "))
+	}
+	return b.String()
+}
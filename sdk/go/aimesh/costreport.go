@@ -0,0 +1,116 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Cost report groupings.
+const (
+	GroupByAgent     = "agent"
+	GroupByTaskGraph = "task_graph"
+	GroupByEndpoint  = "endpoint"
+)
+
+// CostRow is the spend of one group in a cost report.
+type CostRow struct {
+	// Key is the agent, task graph or endpoint ID.
+	Key          string  `json:"key"`
+	Messages     int     `json:"messages"`
+	InputTokens  float64 `json:"input_tokens"`
+	OutputTokens float64 `json:"output_tokens"`
+	TotalTokens  float64 `json:"total_tokens"`
+	// Cost is priced with the endpoints' CostPer1kTokens.
+	Cost float64 `json:"cost"`
+}
+
+// CostReport is token spend over a period, grouped by agent, task graph or
+// endpoint.
+type CostReport struct {
+	FromMs  int64     `json:"from_ms"`
+	ToMs    int64     `json:"to_ms"`
+	GroupBy string    `json:"group_by"`
+	Rows    []CostRow `json:"rows"`
+}
+
+// Total returns the sum of all rows.
+func (r *CostReport) Total() CostRow {
+	total := CostRow{Key: "total"}
+	for _, row := range r.Rows {
+		total.Messages += row.Messages
+		total.InputTokens += row.InputTokens
+		total.OutputTokens += row.OutputTokens
+		total.TotalTokens += row.TotalTokens
+		total.Cost += row.Cost
+	}
+	return total
+}
+
+// WriteCSV writes the report as CSV with a header row.
+func (r *CostReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{r.GroupBy, "messages", "input_tokens", "output_tokens", "total_tokens", "cost"}); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		if err := cw.Write([]string{
+			row.Key,
+			strconv.Itoa(row.Messages),
+			strconv.FormatFloat(row.InputTokens, 'f', -1, 64),
+			strconv.FormatFloat(row.OutputTokens, 'f', -1, 64),
+			strconv.FormatFloat(row.TotalTokens, 'f', -1, 64),
+			strconv.FormatFloat(row.Cost, 'f', -1, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *CostReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// ExportCostReport gets token spend between from and to, grouped by
+// GroupByAgent, GroupByTaskGraph or GroupByEndpoint.
+func (c *Client) ExportCostReport(from, to time.Time, groupBy string, opts ...CallOption) (*CostReport, error) {
+	return c.exportCostReport(context.Background(), from, to, groupBy, opts...)
+}
+
+func (c *Client) exportCostReport(ctx context.Context, from, to time.Time, groupBy string, opts ...CallOption) (*CostReport, error) {
+	switch groupBy {
+	case GroupByAgent, GroupByTaskGraph, GroupByEndpoint:
+	default:
+		return nil, fmt.Errorf("%w: unknown cost report grouping %q", ErrValidation, groupBy)
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: report start must be before its end", ErrValidation)
+	}
+
+	v := url.Values{
+		"from_ms":  {strconv.FormatInt(from.UnixMilli(), 10)},
+		"to_ms":    {strconv.FormatInt(to.UnixMilli(), 10)},
+		"group_by": {groupBy},
+	}
+	data, err := c.requestContext(ctx, "GET", withQuery("/reports/cost", v), nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var report CostReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,67 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ModelPrice is the price of a model per 1000 input and output tokens.
+type ModelPrice struct {
+	InputPer1kTokens  float64 `json:"input_per_1k_tokens"`
+	OutputPer1kTokens float64 `json:"output_per_1k_tokens"`
+}
+
+// CostTable maps model names to prices. It replaces the single
+// CostPer1kTokens of an endpoint for endpoints that host several models.
+type CostTable map[string]ModelPrice
+
+// Cost prices a request to model with the given token counts.
+func (t CostTable) Cost(model string, inputTokens, outputTokens float64) (float64, error) {
+	price, ok := t[model]
+	if !ok {
+		return 0, fmt.Errorf("%w: no price for model %q", ErrNotFound, model)
+	}
+	return inputTokens/1000*price.InputPer1kTokens + outputTokens/1000*price.OutputPer1kTokens, nil
+}
+
+// Validate checks the table for values the broker would reject.
+func (t CostTable) Validate() error {
+	for model, price := range t {
+		if model == "" {
+			return fmt.Errorf("%w: cost table has an empty model name", ErrValidation)
+		}
+		if price.InputPer1kTokens < 0 || price.OutputPer1kTokens < 0 {
+			return fmt.Errorf("%w: negative price for model %q", ErrValidation, model)
+		}
+	}
+	return nil
+}
+
+// PublishCostTable sets the prices the broker uses to account spend. Models
+// already in the broker's table but missing from table are removed.
+func (c *Client) PublishCostTable(table CostTable, opts ...CallOption) error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+	_, err := c.request("PUT", "/cost-table", map[string]interface{}{
+		"models": table,
+	}, opts...)
+	return err
+}
+
+// GetCostTable gets the broker's model prices.
+func (c *Client) GetCostTable(opts ...CallOption) (CostTable, error) {
+	data, err := c.request("GET", "/cost-table", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Models CostTable `json:"models"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Models, nil
+}
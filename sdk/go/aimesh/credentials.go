@@ -0,0 +1,261 @@
+package aimesh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialProvider fetches the API key from where it is stored, such as
+// a secrets manager. Wrap it with NewCachedKeyProvider to use it as
+// ClientConfig.KeyProvider.
+type CredentialProvider interface {
+	Credential(ctx context.Context) (string, error)
+}
+
+// EnvCredential reads the API key from an environment variable.
+type EnvCredential struct {
+	Name string
+}
+
+// Credential implements CredentialProvider.
+func (e *EnvCredential) Credential(ctx context.Context) (string, error) {
+	key := os.Getenv(e.Name)
+	if key == "" {
+		return "", fmt.Errorf("%w: %s is not set", ErrNotFound, e.Name)
+	}
+	return key, nil
+}
+
+// FileCredential reads the API key from a file, such as a mounted
+// Kubernetes secret that is updated in place on rotation.
+type FileCredential struct {
+	Path string
+}
+
+// Credential implements CredentialProvider.
+func (f *FileCredential) Credential(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%w: %s is empty", ErrNotFound, f.Path)
+	}
+	return key, nil
+}
+
+// VaultCredential reads the API key from a HashiCorp Vault KV secret.
+type VaultCredential struct {
+	// Address is the Vault server, such as https://vault:8200.
+	Address string
+	// Token authenticates to Vault. When empty it is read from TokenFile.
+	Token     string
+	TokenFile string
+	// Path is the secret's API path without the /v1 prefix, such as
+	// secret/data/aimesh for a KV version 2 mount.
+	Path string
+	// Field is the key of the secret holding the API key. Defaults to
+	// "api_key".
+	Field string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Credential implements CredentialProvider.
+func (v *VaultCredential) Credential(ctx context.Context) (string, error) {
+	token := v.Token
+	if token == "" && v.TokenFile != "" {
+		data, err := os.ReadFile(v.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(v.Address, "/")+"/v1/"+strings.TrimPrefix(v.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	data, err := fetchSecret(v.HTTPClient, req, "vault")
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	fields := resp.Data
+	// KV version 2 nests the secret under data.data.
+	if nested, ok := resp.Data["data"]; ok {
+		var kv2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2); err == nil {
+			fields = kv2
+		}
+	}
+	return secretField(fields, v.Field, "vault secret "+v.Path)
+}
+
+// AWSSecretCredential reads the API key from AWS Secrets Manager.
+type AWSSecretCredential struct {
+	Region   string
+	SecretID string
+	// Field is the key holding the API key when the secret is a JSON
+	// object. When empty the whole secret string is the key.
+	Field string
+	// Credentials signs the request. Defaults to EnvAWSCredentials.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	// Endpoint overrides the regional Secrets Manager endpoint.
+	Endpoint string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Credential implements CredentialProvider.
+func (a *AWSSecretCredential) Credential(ctx context.Context) (string, error) {
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + a.Region + ".amazonaws.com/"
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": a.SecretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signer := &SigV4Signer{Region: a.Region, Service: "secretsmanager", Credentials: a.Credentials}
+	if err := signer.SignRequest(req, body); err != nil {
+		return "", err
+	}
+	data, err := fetchSecret(a.HTTPClient, req, "secrets manager")
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", err
+	}
+	if a.Field == "" {
+		if resp.SecretString == "" {
+			return "", fmt.Errorf("%w: secret %s has no string value", ErrNotFound, a.SecretID)
+		}
+		return resp.SecretString, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(resp.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", a.SecretID, err)
+	}
+	return secretField(fields, a.Field, "secret "+a.SecretID)
+}
+
+// fetchSecret sends a secret store request and returns the response body.
+func fetchSecret(httpClient *http.Client, req *http.Request, store string) ([]byte, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s: %s", store, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// secretField returns the string value of field, defaulting to "api_key".
+func secretField(fields map[string]json.RawMessage, field, what string) (string, error) {
+	if field == "" {
+		field = "api_key"
+	}
+	var key string
+	if raw, ok := fields[field]; ok {
+		json.Unmarshal(raw, &key)
+	}
+	if key == "" {
+		return "", fmt.Errorf("%w: %s has no field %q", ErrNotFound, what, field)
+	}
+	return key, nil
+}
+
+// CachedKeyProvider is a KeyProvider that fetches the key from a
+// CredentialProvider and refreshes it after a TTL. If a refresh fails the
+// last key keeps being used. A request refused with a 401 forces a refresh,
+// so a rotated secret is picked up immediately.
+type CachedKeyProvider struct {
+	source CredentialProvider
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	key     string
+	refresh time.Time
+}
+
+// NewCachedKeyProvider caches keys from source for ttl, which defaults to
+// five minutes.
+func NewCachedKeyProvider(source CredentialProvider, ttl time.Duration) *CachedKeyProvider {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &CachedKeyProvider{source: source, ttl: ttl}
+}
+
+// APIKey implements KeyProvider.
+func (p *CachedKeyProvider) APIKey(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.key != "" && time.Now().Before(p.refresh) {
+		return p.key, nil
+	}
+	return p.fetch(ctx)
+}
+
+// Refresh fetches the key from the source now.
+func (p *CachedKeyProvider) Refresh(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.fetch(ctx)
+}
+
+func (p *CachedKeyProvider) fetch(ctx context.Context) (string, error) {
+	key, err := p.source.Credential(ctx)
+	if err != nil {
+		if p.key == "" {
+			return "", err
+		}
+		// Keep the last key, but try the source again soon.
+		retry := p.ttl
+		if retry > 30*time.Second {
+			retry = 30 * time.Second
+		}
+		p.refresh = time.Now().Add(retry)
+		return p.key, nil
+	}
+	p.key = key
+	p.refresh = time.Now().Add(p.ttl)
+	return key, nil
+}
@@ -0,0 +1,91 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+// DeadLetter is a message the broker gave up on, together with the reason it
+// was dead-lettered.
+type DeadLetter struct {
+	ID             string  `json:"id"`
+	Message        Message `json:"message"`
+	Reason         string  `json:"reason"`
+	LastError      string  `json:"last_error"`
+	DeliveryCount  int     `json:"delivery_count"`
+	DeadLetteredAt int64   `json:"dead_lettered_at"`
+}
+
+// DeadLetterPage is one page of dead letters.
+type DeadLetterPage struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	NextCursor  string       `json:"next_cursor"`
+}
+
+// ListDeadLetters lists dead-lettered messages for an agent. Pass the
+// NextCursor of a page as opts.Cursor to fetch the following page.
+func (c *Client) ListDeadLetters(agentID string, opts *ListOptions, callOpts ...CallOption) (*DeadLetterPage, error) {
+	v := opts.values()
+	v.Set("agent_id", agentID)
+
+	data, err := c.request("GET", withQuery("/dead-letters", v), nil, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page DeadLetterPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	for i := range page.DeadLetters {
+		page.DeadLetters[i].Message.decodePayload()
+	}
+
+	return &page, nil
+}
+
+// GetDeadLetter gets a single dead letter.
+func (c *Client) GetDeadLetter(id string, opts ...CallOption) (*DeadLetter, error) {
+	data, err := c.request("GET", "/dead-letters/"+id, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var dl DeadLetter
+	if err := json.Unmarshal(data, &dl); err != nil {
+		return nil, err
+	}
+	dl.Message.decodePayload()
+
+	return &dl, nil
+}
+
+// RequeueDeadLetter puts a dead letter back on its agent's queue with a fresh
+// delivery count.
+func (c *Client) RequeueDeadLetter(id string, opts ...CallOption) error {
+	_, err := c.request("POST", "/dead-letters/"+id+"/requeue", nil, opts...)
+	return err
+}
+
+// PurgeDeadLetters deletes all dead letters for an agent and returns how many
+// were removed. An empty agentID purges dead letters for every agent.
+func (c *Client) PurgeDeadLetters(agentID string, opts ...CallOption) (int, error) {
+	path := "/dead-letters"
+	if agentID != "" {
+		path = withQuery(path, url.Values{"agent_id": {agentID}})
+	}
+
+	data, err := c.request("DELETE", path, nil, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Purged int `json:"purged"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Purged, nil
+}
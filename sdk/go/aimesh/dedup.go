@@ -0,0 +1,48 @@
+package aimesh
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// DedupKey derives a deterministic deduplication key from the agent, task
+// graph and payload of a message. Sending the same work twice yields the same
+// key, so the broker can recognize the retry and return the original
+// acknowledgment instead of processing it again.
+func DedupKey(agentID, taskGraphID string, payload []byte) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(agentID), []byte(taskGraphID), payload} {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(part)))
+		h.Write(n[:])
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WithDedupKey sets DedupContext to the DedupKey of the message.
+func (m *Message) WithDedupKey() *Message {
+	m.DedupContext = DedupKey(m.AgentID, m.TaskGraphID, m.Payload)
+	return m
+}
+
+// SendOnce sends a message with exactly-once semantics. If the message has no
+// DedupContext one is derived with WithDedupKey. When the broker reports the
+// message as a duplicate, SendOnce returns the acknowledgment of the original
+// message instead of an error, so retrying a SendOnce call is always safe.
+func (c *Client) SendOnce(msg *Message, opts ...CallOption) (*Acknowledgment, error) {
+	if msg.DedupContext == "" {
+		msg.WithDedupKey()
+	}
+
+	ack, err := c.SendMessage(msg, opts...)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && errors.Is(err, ErrConflict) && len(apiErr.Body) > 0 {
+		if original, decodeErr := decodeAck(apiErr.Body); decodeErr == nil && original.OriginalMessageID != "" {
+			return original, nil
+		}
+	}
+	return ack, err
+}
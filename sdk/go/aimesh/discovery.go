@@ -0,0 +1,197 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discovery resolves the current set of brokers. A client configured with a
+// Discovery polls it and feeds the result to its Balancer, so brokers can be
+// added and removed without reconfiguring producers.
+type Discovery interface {
+	Brokers(ctx context.Context) ([]string, error)
+}
+
+// SRVDiscovery resolves brokers from a DNS SRV record such as
+// _aimesh._tcp.example.com.
+type SRVDiscovery struct {
+	// Service and Proto name the record, e.g. "aimesh" and "tcp". Both may
+	// be empty to look up Name directly.
+	Service string
+	Proto   string
+	Name    string
+	// Scheme is used for the broker URLs. Defaults to "http".
+	Scheme string
+	// Resolver defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// Brokers returns one URL per SRV target, ordered by priority and, within a
+// priority, by descending weight.
+func (d *SRVDiscovery) Brokers(ctx context.Context) ([]string, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	scheme := d.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, d.Service, d.Proto, d.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: resolving SRV record %s: %v", ErrConnection, d.Name, err)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+
+	brokers := make([]string, 0, len(records))
+	for _, r := range records {
+		host := strings.TrimSuffix(r.Target, ".")
+		brokers = append(brokers, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(r.Port))))
+	}
+	return brokers, nil
+}
+
+// DiscoveryWatcher is implemented by Discovery sources that push changes
+// instead of being polled. Watch calls update with the complete broker list
+// whenever it changes, until ctx is done or the watch breaks; the client then
+// watches again.
+type DiscoveryWatcher interface {
+	Discovery
+	Watch(ctx context.Context, update func(brokers []string)) error
+}
+
+// URLDiscovery fetches the broker list from a discovery service that answers
+// GET requests with {"brokers": ["http://broker-1:9000", ...]}.
+type URLDiscovery struct {
+	URL string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Brokers fetches the current broker list.
+func (d *URLDiscovery) Brokers(ctx context.Context) ([]string, error) {
+	client := d.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", d.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, newAPIError(resp.StatusCode, data)
+	}
+
+	var body struct {
+		Brokers []string `json:"brokers"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	return body.Brokers, nil
+}
+
+// discoveryLoop refreshes the client's brokers from a Discovery until the
+// client is closed.
+type discoveryLoop struct {
+	client   *Client
+	source   Discovery
+	interval time.Duration
+	onError  func(error)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (c *Client) startDiscovery(source Discovery, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &discoveryLoop{
+		client:   c,
+		source:   source,
+		interval: interval,
+		onError:  onError,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	c.track(d)
+	go d.run(ctx)
+}
+
+func (d *discoveryLoop) run(ctx context.Context) {
+	defer close(d.done)
+	watcher, watch := d.source.(DiscoveryWatcher)
+	for {
+		if watch {
+			err := watcher.Watch(ctx, func(brokers []string) {
+				d.apply(ctx, brokers, nil)
+			})
+			if err != nil {
+				d.apply(ctx, nil, err)
+				sleepContext(ctx, d.interval)
+			} else {
+				sleepContext(ctx, time.Second)
+			}
+		} else {
+			brokers, err := d.source.Brokers(ctx)
+			d.apply(ctx, brokers, err)
+			sleepContext(ctx, d.interval)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// apply hands a lookup result to the balancer. Failures and empty results
+// keep the last known brokers.
+func (d *discoveryLoop) apply(ctx context.Context, brokers []string, err error) {
+	if err == nil && len(brokers) == 0 {
+		err = fmt.Errorf("%w: discovery returned no brokers", ErrConnection)
+	}
+	if err != nil {
+		if d.onError != nil && ctx.Err() == nil {
+			d.onError(err)
+		}
+		return
+	}
+	d.client.balancer.Update(brokers)
+}
+
+func (d *discoveryLoop) drain(ctx context.Context) error {
+	d.cancel()
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+	}
+	return nil
+}
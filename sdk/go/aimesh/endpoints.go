@@ -0,0 +1,578 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EndpointInfo is the broker's view of a single endpoint.
+type EndpointInfo struct {
+	EndpointMetrics
+	// Assignments is the number of messages currently assigned to the
+	// endpoint.
+	Assignments int  `json:"assignments"`
+	Draining    bool `json:"draining"`
+	// Weight is the endpoint's routing weight; see SetEndpointWeight.
+	Weight int `json:"weight"`
+	// LastReportMs is when the endpoint last registered or reported
+	// metrics.
+	LastReportMs int64 `json:"last_report_ms"`
+	// Revision increases with every change to the endpoint. Pass it in an
+	// EndpointPatch for optimistic concurrency.
+	Revision int64 `json:"revision"`
+}
+
+// GetEndpoint gets a single endpoint's metrics, capabilities and current
+// assignment count. Results are cached for ClientConfig.Cache.EndpointTTL.
+func (c *Client) GetEndpoint(endpointID string, opts ...CallOption) (*EndpointInfo, error) {
+	data, err := c.request("GET", "/endpoints/"+endpointID, nil, append([]CallOption{cached(c.endpointTTL())}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var info EndpointInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// EndpointPatch is a partial endpoint update; nil fields are left
+// unchanged.
+type EndpointPatch struct {
+	Capacity        *int                  `json:"capacity,omitempty"`
+	CurrentLoad     *int                  `json:"current_load,omitempty"`
+	CostPer1kTokens *float64              `json:"cost_per_1k_tokens,omitempty"`
+	LatencyP99Ms    *float64              `json:"latency_p99_ms,omitempty"`
+	ErrorRate       *float64              `json:"error_rate,omitempty"`
+	HealthStatus    *string               `json:"health_status,omitempty"`
+	Capabilities    *EndpointCapabilities `json:"capabilities,omitempty"`
+	// Labels replaces the endpoint's labels when non-nil.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Revision, if non-zero, makes the update fail with ErrConflict unless
+	// the endpoint is still at that revision.
+	Revision int64 `json:"revision,omitempty"`
+}
+
+// UpdateEndpoint applies a partial update to an endpoint and returns the
+// result, including its new revision.
+func (c *Client) UpdateEndpoint(endpointID string, patch *EndpointPatch, opts ...CallOption) (*EndpointInfo, error) {
+	data, err := c.request("PATCH", "/endpoints/"+endpointID, patch, opts...)
+	c.InvalidateEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	var info EndpointInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// SetEndpointWeight sets an endpoint's routing weight. Compatible endpoints
+// receive traffic in proportion to their weights, so a new deployment
+// weighted 5 next to existing endpoints totalling 95 gets a 5% canary share.
+// A weight of zero stops new traffic without draining in-flight work.
+func (c *Client) SetEndpointWeight(endpointID string, weight int, opts ...CallOption) error {
+	if weight < 0 {
+		return fmt.Errorf("%w: weight must not be negative", ErrValidation)
+	}
+	_, err := c.request("PUT", "/endpoints/"+endpointID+"/weight", map[string]interface{}{
+		"weight": weight,
+	}, opts...)
+	c.InvalidateEndpoint(endpointID)
+	return err
+}
+
+// Endpoint registry event types.
+const (
+	EndpointAdded   = "added"
+	EndpointUpdated = "updated"
+	EndpointRemoved = "removed"
+)
+
+// EndpointEvent is a change to the endpoint registry.
+type EndpointEvent struct {
+	Type     string
+	Endpoint EndpointInfo
+}
+
+// EndpointWatcher streams endpoint registry changes.
+type EndpointWatcher struct {
+	events chan EndpointEvent
+
+	mu  sync.Mutex
+	err error
+}
+
+// Events returns the channel of registry changes. It is closed when the
+// watch ends.
+func (w *EndpointWatcher) Events() <-chan EndpointEvent {
+	return w.events
+}
+
+// Err returns the error that ended the watch, or nil while it runs or if it
+// ended because its context was done.
+func (w *EndpointWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// WatchEndpoints streams endpoint registry changes as the broker pushes
+// them. The stream reconnects and resumes after interruptions; the watch
+// ends when ctx is done or a reconnect fails with an error that retrying
+// cannot fix, such as ErrUnauthorized, which Err then returns. WithSelector
+// limits the events to matching endpoints. It fails with ErrUnsupported if
+// the broker does not advertise FeatureStreaming.
+func (c *Client) WatchEndpoints(ctx context.Context, opts ...CallOption) (*EndpointWatcher, error) {
+	o := c.callOptions(opts)
+	if o.err != nil {
+		return nil, o.err
+	}
+	if !c.supports(ctx, FeatureStreaming) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, FeatureStreaming)
+	}
+	resp, err := c.openStream(ctx, "GET", "/endpoints/watch", nil, o, "")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &EndpointWatcher{events: make(chan EndpointEvent)}
+	go func() {
+		defer close(w.events)
+		lastID := ""
+		for attempt := 0; ; attempt++ {
+			if resp != nil {
+				attempt = 0
+				lastID = c.forwardEndpointEvents(ctx, resp, w.events, lastID)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			sleepContext(ctx, retryBackoff(attempt))
+			resp, err = c.openStream(ctx, "GET", "/endpoints/watch", nil, o, lastID)
+			if err != nil && ctx.Err() == nil && !failsOver(err) && !retryable(err) {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+				return
+			}
+		}
+	}()
+	return w, nil
+}
+
+// forwardEndpointEvents relays events from one stream until it ends. It
+// returns the ID of the last event for resuming.
+func (c *Client) forwardEndpointEvents(ctx context.Context, resp *http.Response, events chan<- EndpointEvent, lastID string) string {
+	defer resp.Body.Close()
+	r := newSSEReader(resp.Body)
+	for {
+		ev, err := r.next()
+		if err != nil {
+			return lastID
+		}
+		if ev.ID != "" {
+			lastID = ev.ID
+		}
+		var info EndpointInfo
+		if err := json.Unmarshal([]byte(ev.Data), &info); err != nil {
+			continue
+		}
+		select {
+		case events <- EndpointEvent{Type: ev.Event, Endpoint: info}:
+		case <-ctx.Done():
+			return lastID
+		}
+	}
+}
+
+// DrainEndpoint puts an endpoint into maintenance mode: the broker stops
+// assigning new work to it while work already assigned finishes. Use it
+// before upgrading the model server behind the endpoint.
+func (c *Client) DrainEndpoint(endpointID string, opts ...CallOption) error {
+	_, err := c.request("POST", "/endpoints/"+endpointID+"/drain", nil, opts...)
+	c.InvalidateEndpoint(endpointID)
+	return err
+}
+
+// ResumeEndpoint takes an endpoint out of maintenance mode so that it
+// receives new work again.
+func (c *Client) ResumeEndpoint(endpointID string, opts ...CallOption) error {
+	_, err := c.request("POST", "/endpoints/"+endpointID+"/resume", nil, opts...)
+	c.InvalidateEndpoint(endpointID)
+	return err
+}
+
+// EndpointReporter periodically pushes fresh metrics for a registered
+// endpoint, so the broker routes on current load and can expire endpoints
+// that stop reporting.
+type EndpointReporter struct {
+	client     *Client
+	endpointID string
+	interval   time.Duration
+	metrics    func() *EndpointMetrics
+
+	mu      sync.Mutex
+	lastErr error
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartEndpointReporter reports metrics from metricsFn for endpointID every
+// interval until Stop or Close is called. If the broker no longer knows the
+// endpoint, it is registered again with the latest metrics. A tick where
+// metricsFn returns nil is skipped and recorded as an error.
+func (c *Client) StartEndpointReporter(endpointID string, interval time.Duration, metricsFn func() *EndpointMetrics) (*EndpointReporter, error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &EndpointReporter{
+		client:     c,
+		endpointID: endpointID,
+		interval:   interval,
+		metrics:    metricsFn,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	if err := c.track(r); err != nil {
+		cancel()
+		return nil, err
+	}
+	go r.run(ctx)
+	return r, nil
+}
+
+func (r *EndpointReporter) run(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		err := r.report(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		r.mu.Lock()
+		r.lastErr = err
+		r.mu.Unlock()
+	}
+}
+
+// report pushes one set of metrics.
+func (r *EndpointReporter) report(ctx context.Context) error {
+	metrics := r.metrics()
+	if metrics == nil {
+		return fmt.Errorf("%w: metrics function for endpoint %s returned nil", ErrValidation, r.endpointID)
+	}
+	metrics.EndpointID = r.endpointID
+
+	_, err := r.client.requestContext(ctx, "POST", "/endpoints/"+r.endpointID+"/heartbeat", metrics)
+	if errors.Is(err, ErrNotFound) {
+		_, err = r.client.requestContext(ctx, "POST", "/endpoints", metrics)
+	}
+	return err
+}
+
+// Err returns the error of the most recent report, or nil if it succeeded.
+func (r *EndpointReporter) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// Stop stops reporting.
+func (r *EndpointReporter) Stop() {
+	r.stopOnce.Do(func() {
+		r.client.untrack(r)
+		r.cancel()
+		<-r.done
+	})
+}
+
+func (r *EndpointReporter) drain(ctx context.Context) error {
+	r.Stop()
+	return nil
+}
+
+// LeaseOptions configures a lease-based endpoint registration.
+type LeaseOptions struct {
+	// TTL is how long the broker keeps the endpoint without a renewal.
+	// Defaults to 30 seconds; the lease is renewed every third of it.
+	TTL time.Duration
+	// OnLost is called, from the renewal goroutine, when the lease cannot
+	// be renewed before it expires or the broker no longer knows it. The
+	// endpoint is gone by then and can be registered again.
+	OnLost func(lease *EndpointLease, err error)
+}
+
+// EndpointLease keeps an endpoint registered only as long as this process
+// renews it, so the broker expires endpoints of processes that died.
+type EndpointLease struct {
+	EndpointID string        `json:"endpoint_id"`
+	LeaseID    string        `json:"lease_id"`
+	TTL        time.Duration `json:"-"`
+
+	client   *Client
+	onLost   func(lease *EndpointLease, err error)
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// RegisterEndpointWithLease registers an endpoint under a lease that is
+// renewed in the background until Revoke or Close is called.
+func (c *Client) RegisterEndpointWithLease(metrics *EndpointMetrics, opts *LeaseOptions, callOpts ...CallOption) (*EndpointLease, error) {
+	if opts == nil {
+		opts = &LeaseOptions{}
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+
+	data, err := c.request("POST", "/endpoints?lease_ttl_ms="+strconv.FormatInt(ttl.Milliseconds(), 10), metrics, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		LeaseID string `json:"lease_id"`
+		TTLMs   int64  `json:"ttl_ms"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.TTLMs > 0 {
+		ttl = time.Duration(resp.TTLMs) * time.Millisecond
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lease := &EndpointLease{
+		EndpointID: metrics.EndpointID,
+		LeaseID:    resp.LeaseID,
+		TTL:        ttl,
+		client:     c,
+		onLost:     opts.OnLost,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	c.registered(metrics.EndpointID, false)
+	if err := c.track(lease); err != nil {
+		cancel()
+		return nil, err
+	}
+	go lease.renew(ctx)
+	return lease, nil
+}
+
+// renew keeps the lease alive until it is stopped or lost.
+func (l *EndpointLease) renew(ctx context.Context) {
+	err := l.keepAlive(ctx)
+	close(l.done)
+	if err != nil && l.onLost != nil {
+		l.onLost(l, err)
+	}
+}
+
+// keepAlive renews the lease every third of its TTL. It returns nil when
+// stopped, or the error that lost the lease.
+func (l *EndpointLease) keepAlive(ctx context.Context) error {
+	ticker := time.NewTicker(l.TTL / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		_, err := l.client.requestContext(ctx, "POST", "/leases/"+l.LeaseID+"/renew", nil, WithTimeout(l.TTL/3))
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			renewed = time.Now()
+			continue
+		}
+		if errors.Is(err, ErrNotFound) || time.Since(renewed) >= l.TTL {
+			l.client.untrack(l)
+			l.client.registered(l.EndpointID, true)
+			l.client.InvalidateEndpoint(l.EndpointID)
+			return err
+		}
+	}
+}
+
+// Revoke stops renewing the lease and releases it, removing the endpoint
+// right away instead of waiting for the TTL to run out.
+func (l *EndpointLease) Revoke() error {
+	l.stop()
+	_, err := l.client.request("DELETE", "/leases/"+l.LeaseID, nil)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		l.client.registered(l.EndpointID, true)
+		l.client.InvalidateEndpoint(l.EndpointID)
+		return nil
+	}
+	return err
+}
+
+func (l *EndpointLease) stop() {
+	l.stopOnce.Do(func() {
+		l.client.untrack(l)
+		l.cancel()
+		<-l.done
+	})
+}
+
+// drain stops renewal; Close then removes the endpoint itself.
+func (l *EndpointLease) drain(ctx context.Context) error {
+	l.stop()
+	return nil
+}
+
+// Endpoint list orderings.
+const (
+	SortByID        = "id"
+	SortByLoad      = "load"
+	SortByLatency   = "latency"
+	SortByCost      = "cost"
+	SortByErrorRate = "error_rate"
+)
+
+// EndpointListOptions controls ListEndpointsPage.
+type EndpointListOptions struct {
+	ListOptions
+	// SortBy orders the endpoints. Defaults to SortByID.
+	SortBy     string
+	Descending bool
+	// HealthStatus only lists endpoints in this health status, such as
+	// "healthy".
+	HealthStatus string
+}
+
+// EndpointPage is one page of endpoints.
+type EndpointPage struct {
+	Endpoints  []EndpointMetrics `json:"endpoints"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+// ListEndpointsPage lists one page of endpoints. Pass the NextCursor of a
+// page as opts.Cursor to fetch the following page, or use Endpoints to
+// iterate over all of them. WithSelector filters by labels.
+func (c *Client) ListEndpointsPage(opts *EndpointListOptions, callOpts ...CallOption) (*EndpointPage, error) {
+	if opts == nil {
+		opts = &EndpointListOptions{}
+	}
+	switch opts.SortBy {
+	case "", SortByID, SortByLoad, SortByLatency, SortByCost, SortByErrorRate:
+	default:
+		return nil, fmt.Errorf("%w: unknown endpoint ordering %q", ErrValidation, opts.SortBy)
+	}
+
+	v := opts.values()
+	if opts.SortBy != "" {
+		v.Set("sort", opts.SortBy)
+	}
+	if opts.Descending {
+		v.Set("order", "desc")
+	}
+	if opts.HealthStatus != "" {
+		v.Set("health_status", opts.HealthStatus)
+	}
+
+	data, err := c.request("GET", withQuery("/endpoints", v), nil, append([]CallOption{conditional()}, callOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page EndpointPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// EndpointsIterator walks all endpoints page by page:
+//
+//	it := client.Endpoints(&aimesh.EndpointListOptions{HealthStatus: "healthy"})
+//	for it.Next() {
+//		ep := it.Endpoint()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type EndpointsIterator struct {
+	client   *Client
+	opts     EndpointListOptions
+	callOpts []CallOption
+
+	page []EndpointMetrics
+	i    int
+	last bool
+	err  error
+}
+
+// Endpoints returns an iterator over all endpoints matching opts. Pages are
+// fetched as the iteration reaches them.
+func (c *Client) Endpoints(opts *EndpointListOptions, callOpts ...CallOption) *EndpointsIterator {
+	it := &EndpointsIterator{client: c, callOpts: callOpts, i: -1}
+	if opts != nil {
+		it.opts = *opts
+	}
+	return it
+}
+
+// Next advances to the next endpoint, fetching the next page when needed.
+// It returns false when there are no more endpoints or a fetch failed.
+func (it *EndpointsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.i++
+	for it.i >= len(it.page) {
+		if it.last {
+			return false
+		}
+		page, err := it.client.ListEndpointsPage(&it.opts, it.callOpts...)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.i = page.Endpoints, 0
+		it.opts.Cursor = page.NextCursor
+		it.last = page.NextCursor == ""
+	}
+	return true
+}
+
+// Endpoint returns the current endpoint.
+func (it *EndpointsIterator) Endpoint() *EndpointMetrics {
+	return &it.page[it.i]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *EndpointsIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,87 @@
+package aimesh
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DefaultBudgetTokens is the budget given to messages that do not set one.
+const DefaultBudgetTokens = 1000
+
+// EstimateOptions configures EstimateCost.
+type EstimateOptions struct {
+	// CompletionTokens is the expected length of the model's answer. When
+	// zero it is CompletionRatio times the prompt length.
+	CompletionTokens float64
+	// CompletionRatio is the expected completion length relative to the
+	// prompt. Defaults to 1.
+	CompletionRatio float64
+	// SafetyMargin is the fraction added on top of the estimate to get the
+	// recommended budget. Defaults to 0.2.
+	SafetyMargin float64
+	// CostTable prices the estimate. When nil the broker's table is fetched.
+	CostTable CostTable
+}
+
+// CostEstimate is the outcome of EstimateCost.
+type CostEstimate struct {
+	Model        string
+	InputTokens  float64
+	OutputTokens float64
+	// Cost is the price of the estimate per the cost table, or zero if the
+	// table has no price for the model.
+	Cost float64
+	// BudgetTokens is the estimate plus the safety margin.
+	BudgetTokens float64
+}
+
+// TotalTokens returns the estimated input plus output tokens.
+func (e *CostEstimate) TotalTokens() float64 {
+	return e.InputTokens + e.OutputTokens
+}
+
+// EstimateCost counts msg's prompt tokens for model, adds the expected
+// completion and prices the result. It sets msg.EstimatedCostToken to the
+// estimated total and msg.BudgetTokens to the estimate plus the safety margin.
+func (c *Client) EstimateCost(msg *Message, model string, opts *EstimateOptions, callOpts ...CallOption) (*CostEstimate, error) {
+	var o EstimateOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.CompletionTokens < 0 || o.CompletionRatio < 0 || o.SafetyMargin < 0 {
+		return nil, fmt.Errorf("%w: estimate options must not be negative", ErrValidation)
+	}
+	if o.CompletionRatio == 0 {
+		o.CompletionRatio = 1
+	}
+	if o.SafetyMargin == 0 {
+		o.SafetyMargin = 0.2
+	}
+
+	table := o.CostTable
+	if table == nil {
+		var err error
+		table, err = c.GetCostTable(callOpts...)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	est := &CostEstimate{
+		Model:       model,
+		InputTokens: float64(TokenizerFor(model).CountTokens(msg.Payload)),
+	}
+	est.OutputTokens = o.CompletionTokens
+	if est.OutputTokens == 0 {
+		est.OutputTokens = math.Ceil(est.InputTokens * o.CompletionRatio)
+	}
+	if cost, err := table.Cost(model, est.InputTokens, est.OutputTokens); err == nil {
+		est.Cost = cost
+	}
+	est.BudgetTokens = math.Ceil(est.TotalTokens() * (1 + o.SafetyMargin))
+
+	msg.EstimatedCostToken = est.TotalTokens()
+	msg.BudgetTokens = est.BudgetTokens
+	return est, nil
+}
@@ -0,0 +1,67 @@
+package aimesh
+
+import (
+	"net/http"
+	"sync"
+)
+
+// maxETags bounds how many list responses are kept for revalidation.
+const maxETags = 256
+
+type etagEntry struct {
+	etag string
+	body []byte
+}
+
+// etagStore keeps the last response of conditional reads, keyed by broker
+// and readKey, so that an unchanged list is answered with 304 Not Modified
+// instead of being transferred again.
+type etagStore struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+func (s *etagStore) get(key string) (etagEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+func (s *etagStore) put(key, etag string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]etagEntry)
+	}
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= maxETags {
+		for k := range s.entries {
+			delete(s.entries, k)
+			break
+		}
+	}
+	s.entries[key] = etagEntry{etag: etag, body: body}
+}
+
+// conditional is the internal option sending a list read with
+// If-None-Match, returning the previous result when the broker answers 304.
+func conditional() CallOption {
+	return func(o *callOptions) {
+		o.conditional = true
+	}
+}
+
+// revalidate adds If-None-Match to a conditional read of key that has a
+// stored response, and returns the header to send along with that entry.
+func (c *Client) revalidate(key string, header http.Header) (http.Header, *etagEntry) {
+	e, ok := c.etags.get(key)
+	if !ok {
+		return header, nil
+	}
+	header = header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("If-None-Match", e.etag)
+	return header, &e
+}
@@ -0,0 +1,199 @@
+package aimesh
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Filter expressions select which messages the broker delivers on Receive
+// and topic subscriptions, so consumers do not pay to fetch messages they
+// would discard. The grammar is:
+//
+//	expr    = or
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" expr ")" | compare
+//	compare = operand [ ( "==" | "!=" | ">" | ">=" | "<" | "<=" ) operand ]
+//	operand = field | string | number | "true" | "false"
+//
+// Fields are message fields such as priority, agent_id or task_graph_id, and
+// metadata.<key> for metadata values:
+//
+//	metadata.model == "gpt-4" && priority > 70
+//
+// The broker evaluates filters; ValidateFilter only checks the syntax.
+
+// ValidateFilter checks that expr is a syntactically valid filter expression.
+func ValidateFilter(expr string) error {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return err
+	}
+	p := &filterParser{toks: toks}
+	if err := p.parseOr(); err != nil {
+		return err
+	}
+	if p.pos < len(p.toks) {
+		return p.errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return nil
+}
+
+type filterTokenKind int
+
+const (
+	filterOperand filterTokenKind = iota
+	filterCompare
+	filterAnd
+	filterOr
+	filterNot
+	filterLParen
+	filterRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+func lexFilter(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			toks = append(toks, filterToken{filterLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, filterToken{filterRParen, ")", i})
+			i++
+		case strings.HasPrefix(expr[i:], "&&"):
+			toks = append(toks, filterToken{filterAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "||"):
+			toks = append(toks, filterToken{filterOr, "||", i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="),
+			strings.HasPrefix(expr[i:], ">="), strings.HasPrefix(expr[i:], "<="):
+			toks = append(toks, filterToken{filterCompare, expr[i : i+2], i})
+			i += 2
+		case c == '>' || c == '<':
+			toks = append(toks, filterToken{filterCompare, expr[i : i+1], i})
+			i++
+		case c == '!':
+			toks = append(toks, filterToken{filterNot, "!", i})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("%w: unterminated string at offset %d in filter", ErrValidation, i)
+			}
+			toks = append(toks, filterToken{filterOperand, expr[i : end+1], i})
+			i = end + 1
+		case isFilterWordChar(rune(c)) || c == '-':
+			end := i + 1
+			for end < len(expr) && isFilterWordChar(rune(expr[end])) {
+				end++
+			}
+			toks = append(toks, filterToken{filterOperand, expr[i:end], i})
+			i = end
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at offset %d in filter", ErrValidation, c, i)
+		}
+	}
+	return toks, nil
+}
+
+func isFilterWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+type filterParser struct {
+	toks []filterToken
+	pos  int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.toks) {
+		return filterToken{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	where := "end of filter"
+	if t, ok := p.peek(); ok {
+		where = fmt.Sprintf("offset %d", t.pos)
+	}
+	return fmt.Errorf("%w: %s at %s", ErrValidation, fmt.Sprintf(format, args...), where)
+}
+
+func (p *filterParser) parseOr() error {
+	if err := p.parseAnd(); err != nil {
+		return err
+	}
+	for t, ok := p.peek(); ok && t.kind == filterOr; t, ok = p.peek() {
+		p.pos++
+		if err := p.parseAnd(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *filterParser) parseAnd() error {
+	if err := p.parseUnary(); err != nil {
+		return err
+	}
+	for t, ok := p.peek(); ok && t.kind == filterAnd; t, ok = p.peek() {
+		p.pos++
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *filterParser) parseUnary() error {
+	t, ok := p.peek()
+	if !ok {
+		return p.errorf("expected expression")
+	}
+	switch t.kind {
+	case filterNot:
+		p.pos++
+		return p.parseUnary()
+	case filterLParen:
+		p.pos++
+		if err := p.parseOr(); err != nil {
+			return err
+		}
+		if t, ok := p.peek(); !ok || t.kind != filterRParen {
+			return p.errorf("expected )")
+		}
+		p.pos++
+		return nil
+	case filterOperand:
+		p.pos++
+		if t, ok := p.peek(); ok && t.kind == filterCompare {
+			p.pos++
+			if t, ok := p.peek(); !ok || t.kind != filterOperand {
+				return p.errorf("expected operand")
+			}
+			p.pos++
+		}
+		return nil
+	default:
+		return p.errorf("unexpected %q", t.text)
+	}
+}
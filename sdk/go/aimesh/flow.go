@@ -0,0 +1,55 @@
+package aimesh
+
+import "sync"
+
+// credits is a flow-control window. Consumers take credits before fetching
+// messages and return them as messages are settled, so the broker never hands
+// out more messages than the consumer has room for.
+type credits struct {
+	mu        sync.Mutex
+	available int
+	signal    chan struct{}
+}
+
+func newCredits(n int) *credits {
+	return &credits{available: n, signal: make(chan struct{}, 1)}
+}
+
+// acquire takes up to max credits, blocking while none are available. It
+// returns 0 if done is closed first.
+func (c *credits) acquire(max int, done <-chan struct{}) int {
+	for {
+		c.mu.Lock()
+		if c.available > 0 {
+			n := max
+			if n > c.available {
+				n = c.available
+			}
+			c.available -= n
+			c.mu.Unlock()
+			return n
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.signal:
+		case <-done:
+			return 0
+		}
+	}
+}
+
+// release returns n credits to the window.
+func (c *credits) release(n int) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.available += n
+	c.mu.Unlock()
+
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
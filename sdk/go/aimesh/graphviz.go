@@ -0,0 +1,125 @@
+package aimesh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stateColors maps node states to fill colors used by the exporters.
+var stateColors = map[NodeState]string{
+	NodePending:   "#e0e0e0",
+	NodeBlocked:   "#fff3bf",
+	NodeRunning:   "#a5d8ff",
+	NodeSucceeded: "#b2f2bb",
+	NodeFailed:    "#ffc9c9",
+	NodeCancelled: "#ced4da",
+}
+
+// ExportDOT renders the graph in Graphviz DOT format. Edges point from a
+// dependency to the node that depends on it.
+func (g *TaskGraph) ExportDOT() string {
+	return g.ExportDOTWithStatus(nil)
+}
+
+// ExportDOTWithStatus renders the graph in Graphviz DOT format, coloring each
+// node by its state in status, as returned by GetTaskGraph.
+func (g *TaskGraph) ExportDOTWithStatus(status *TaskGraphStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", "taskgraph-"+g.ID)
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"white\"];\n")
+
+	for _, id := range g.order {
+		label := g.nodeLabel(id, status)
+		attrs := fmt.Sprintf("label=%q", label)
+		if color, ok := g.nodeColor(id, status); ok {
+			attrs += fmt.Sprintf(", fillcolor=%q", color)
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", id, attrs)
+	}
+	for _, id := range g.order {
+		for _, dep := range g.nodes[id].msg.Dependencies {
+			fmt.Fprintf(&b, "  %q -> %q;\n", dep, id)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExportMermaid renders the graph as a Mermaid flowchart.
+func (g *TaskGraph) ExportMermaid() string {
+	return g.ExportMermaidWithStatus(nil)
+}
+
+// ExportMermaidWithStatus renders the graph as a Mermaid flowchart, styling
+// each node by its state in status, as returned by GetTaskGraph.
+func (g *TaskGraph) ExportMermaidWithStatus(status *TaskGraphStatus) string {
+	// Mermaid IDs must be simple identifiers, so nodes are numbered.
+	ids := make(map[string]string, len(g.order))
+	for i, id := range g.order {
+		ids[id] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, id := range g.order {
+		label := strings.ReplaceAll(g.nodeLabel(id, status), "\n", "<br/>")
+		label = strings.ReplaceAll(label, `"`, "#quot;")
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[id], label)
+	}
+	for _, id := range g.order {
+		for _, dep := range g.nodes[id].msg.Dependencies {
+			if from, ok := ids[dep]; ok {
+				fmt.Fprintf(&b, "  %s --> %s\n", from, ids[id])
+			}
+		}
+	}
+
+	if status != nil {
+		used := make(map[NodeState]bool)
+		for _, id := range g.order {
+			if n := status.Node(id); n != nil {
+				if _, ok := stateColors[n.State]; ok {
+					fmt.Fprintf(&b, "  class %s %s\n", ids[id], n.State)
+					used[n.State] = true
+				}
+			}
+		}
+		for _, state := range []NodeState{NodePending, NodeBlocked, NodeRunning, NodeSucceeded, NodeFailed, NodeCancelled} {
+			if used[state] {
+				fmt.Fprintf(&b, "  classDef %s fill:%s\n", state, stateColors[state])
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// nodeLabel returns the display label of a node: its agent, a short ID and,
+// if known, its state.
+func (g *TaskGraph) nodeLabel(id string, status *TaskGraphStatus) string {
+	short := id
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	label := g.nodes[id].msg.AgentID + "\n" + short
+	if status != nil {
+		if n := status.Node(id); n != nil {
+			label += "\n" + string(n.State)
+		}
+	}
+	return label
+}
+
+func (g *TaskGraph) nodeColor(id string, status *TaskGraphStatus) (string, bool) {
+	if status == nil {
+		return "", false
+	}
+	n := status.Node(id)
+	if n == nil {
+		return "", false
+	}
+	color, ok := stateColors[n.State]
+	return color, ok
+}
@@ -0,0 +1,305 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConsumerGroupOptions configures consumer group membership.
+type ConsumerGroupOptions struct {
+	// MemberID identifies this member. Defaults to a random ID.
+	MemberID string
+	// HeartbeatInterval is how often the member heartbeats. Defaults to 3s.
+	HeartbeatInterval time.Duration
+	// SessionTimeout is how long the broker waits without a heartbeat before
+	// evicting the member and reassigning its partitions. Defaults to 15s.
+	SessionTimeout time.Duration
+	// OnAssigned is called with partitions newly assigned to this member.
+	OnAssigned func(partitions []int)
+	// OnRevoked is called with partitions taken away from this member,
+	// before they are released to other members.
+	OnRevoked func(partitions []int)
+}
+
+// ConsumerGroup is a membership in a consumer group. The broker splits the
+// agent's queue into partitions and assigns each partition to exactly one
+// member, so every message is processed by one replica.
+//
+// Rebalancing is cooperative: when members join or leave, only the
+// partitions that move are revoked, and the member finishes its in-flight
+// messages on a revoked partition before releasing it.
+type ConsumerGroup struct {
+	client   *Client
+	groupID  string
+	agentID  string
+	memberID string
+	opts     ConsumerGroupOptions
+
+	mu         sync.Mutex
+	generation int
+	partitions []int
+	inFlight   map[int]int
+	drained    *sync.Cond
+
+	done      chan struct{}
+	stopped   chan struct{}
+	leaveOnce sync.Once
+}
+
+// groupAssignment is the broker's response to join and heartbeat calls.
+type groupAssignment struct {
+	Generation int   `json:"generation"`
+	Partitions []int `json:"partitions"`
+}
+
+// JoinConsumerGroup joins groupID as a consumer of agentID's queue and starts
+// heartbeating. Call Leave to exit the group.
+func (c *Client) JoinConsumerGroup(groupID, agentID string, opts *ConsumerGroupOptions, callOpts ...CallOption) (*ConsumerGroup, error) {
+	var o ConsumerGroupOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MemberID == "" {
+		o.MemberID = uuid.New().String()
+	}
+	if o.HeartbeatInterval <= 0 {
+		o.HeartbeatInterval = 3 * time.Second
+	}
+	if o.SessionTimeout <= 0 {
+		o.SessionTimeout = 15 * time.Second
+	}
+
+	g := &ConsumerGroup{
+		client:   c,
+		groupID:  groupID,
+		agentID:  agentID,
+		memberID: o.MemberID,
+		opts:     o,
+		inFlight: make(map[int]int),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	g.drained = sync.NewCond(&g.mu)
+
+	data, err := c.request("POST", "/consumer-groups/"+groupID+"/members", map[string]interface{}{
+		"member_id":          g.memberID,
+		"agent_id":           agentID,
+		"session_timeout_ms": o.SessionTimeout.Milliseconds(),
+	}, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+	var assignment groupAssignment
+	if err := json.Unmarshal(data, &assignment); err != nil {
+		return nil, err
+	}
+	g.apply(assignment)
+
+	go g.heartbeat()
+	if err := c.track(g); err != nil {
+		g.Leave()
+		return nil, err
+	}
+	return g, nil
+}
+
+// MemberID returns this member's ID.
+func (g *ConsumerGroup) MemberID() string {
+	return g.memberID
+}
+
+// Partitions returns the partitions currently assigned to this member.
+func (g *ConsumerGroup) Partitions() []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]int(nil), g.partitions...)
+}
+
+func (g *ConsumerGroup) memberPath() string {
+	return "/consumer-groups/" + g.groupID + "/members/" + g.memberID
+}
+
+// Receive pulls messages from the partitions assigned to this member. Call
+// Done for each message once it has been acked or nacked.
+func (g *ConsumerGroup) Receive(opts *ReceiveOptions) ([]Message, error) {
+	return g.receive(context.Background(), opts)
+}
+
+func (g *ConsumerGroup) receive(ctx context.Context, opts *ReceiveOptions) ([]Message, error) {
+	g.mu.Lock()
+	generation := g.generation
+	g.mu.Unlock()
+
+	msgs, err := g.client.receive(ctx, g.memberPath()+"/messages?generation="+strconv.Itoa(generation), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	for i := range msgs {
+		g.inFlight[msgs[i].Partition]++
+	}
+	g.mu.Unlock()
+
+	return msgs, nil
+}
+
+// Done marks a message received through the group as settled.
+func (g *ConsumerGroup) Done(msg *Message) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inFlight[msg.Partition] > 0 {
+		g.inFlight[msg.Partition]--
+	}
+	if g.inFlight[msg.Partition] == 0 {
+		delete(g.inFlight, msg.Partition)
+		g.drained.Broadcast()
+	}
+}
+
+// heartbeat keeps the membership alive and applies rebalances.
+func (g *ConsumerGroup) heartbeat() {
+	defer close(g.stopped)
+
+	ticker := time.NewTicker(g.opts.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+		}
+
+		g.mu.Lock()
+		generation := g.generation
+		g.mu.Unlock()
+
+		data, err := g.client.request("POST", g.memberPath()+"/heartbeat", map[string]interface{}{
+			"generation": generation,
+		})
+		if err != nil {
+			continue
+		}
+		var assignment groupAssignment
+		if err := json.Unmarshal(data, &assignment); err != nil {
+			continue
+		}
+		if assignment.Generation != generation {
+			g.rebalance(assignment)
+		}
+	}
+}
+
+// rebalance revokes partitions that moved away, waits for their in-flight
+// messages, confirms the release to the broker and then takes on the new
+// assignment.
+func (g *ConsumerGroup) rebalance(next groupAssignment) {
+	g.mu.Lock()
+	revoked := difference(g.partitions, next.Partitions)
+	g.mu.Unlock()
+
+	if len(revoked) > 0 {
+		if g.opts.OnRevoked != nil {
+			g.opts.OnRevoked(revoked)
+		}
+		g.waitDrained(revoked, g.opts.SessionTimeout/2)
+	}
+
+	_, err := g.client.request("POST", g.memberPath()+"/sync", map[string]interface{}{
+		"generation": next.Generation,
+		"revoked":    revoked,
+	})
+	if err != nil {
+		return
+	}
+	g.apply(next)
+}
+
+// apply installs an assignment and reports newly assigned partitions.
+func (g *ConsumerGroup) apply(next groupAssignment) {
+	g.mu.Lock()
+	added := difference(next.Partitions, g.partitions)
+	g.generation = next.Generation
+	g.partitions = append([]int(nil), next.Partitions...)
+	sort.Ints(g.partitions)
+	g.mu.Unlock()
+
+	if len(added) > 0 && g.opts.OnAssigned != nil {
+		g.opts.OnAssigned(added)
+	}
+}
+
+// waitDrained waits until no messages from partitions are in flight, or
+// until timeout passes.
+func (g *ConsumerGroup) waitDrained(partitions []int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		g.mu.Lock()
+		g.drained.Broadcast()
+		g.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for time.Now().Before(deadline) {
+		busy := false
+		for _, p := range partitions {
+			if g.inFlight[p] > 0 {
+				busy = true
+				break
+			}
+		}
+		if !busy {
+			return
+		}
+		g.drained.Wait()
+	}
+}
+
+// Leave stops heartbeating and leaves the group, releasing this member's
+// partitions to the remaining members.
+func (g *ConsumerGroup) Leave() error {
+	var err error
+	g.leaveOnce.Do(func() {
+		g.client.untrack(g)
+		close(g.done)
+		<-g.stopped
+		_, err = g.client.request("DELETE", g.memberPath(), nil)
+	})
+	return err
+}
+
+func (g *ConsumerGroup) drain(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- g.Leave()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// difference returns the elements of a that are not in b.
+func difference(a, b []int) []int {
+	in := make(map[int]bool, len(b))
+	for _, x := range b {
+		in[x] = true
+	}
+	var out []int
+	for _, x := range a {
+		if !in[x] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
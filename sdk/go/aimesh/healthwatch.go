@@ -0,0 +1,134 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthState is the broker's health as seen by a HealthWatcher.
+type HealthState string
+
+// Health states.
+const (
+	HealthUnknown  HealthState = "unknown"
+	HealthHealthy  HealthState = "healthy"
+	HealthDegraded HealthState = "degraded"
+	HealthDown     HealthState = "down"
+)
+
+// healthState classifies a health check result. A broker that answers but
+// does not report itself healthy is degraded; one that cannot be reached is
+// down.
+func healthState(status *HealthStatus, err error) HealthState {
+	if err != nil {
+		return HealthDown
+	}
+	switch strings.ToLower(status.Status) {
+	case "healthy", "ok":
+		return HealthHealthy
+	default:
+		return HealthDegraded
+	}
+}
+
+// HealthWatcher polls the broker's health and reports state changes.
+type HealthWatcher struct {
+	client   *Client
+	interval time.Duration
+	onChange func(from, to HealthState, status *HealthStatus)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	state HealthState
+}
+
+// StartHealthWatcher checks the broker's health every interval (default
+// 5s) and calls onChange, from the watcher's goroutine, whenever the state
+// changes, starting with the first check. status is nil when the broker is
+// down.
+func (c *Client) StartHealthWatcher(interval time.Duration, onChange func(from, to HealthState, status *HealthStatus)) (*HealthWatcher, error) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &HealthWatcher{
+		client:   c,
+		interval: interval,
+		onChange: onChange,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		state:    HealthUnknown,
+	}
+	if err := c.track(w); err != nil {
+		cancel()
+		return nil, err
+	}
+	go w.run(ctx)
+	return w, nil
+}
+
+func (w *HealthWatcher) run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *HealthWatcher) check(ctx context.Context) {
+	data, err := w.client.requestContext(ctx, "GET", "/health", nil, WithTimeout(w.interval), WithRetries(0))
+	if ctx.Err() != nil {
+		return
+	}
+	var status *HealthStatus
+	if err == nil {
+		status = &HealthStatus{}
+		if err = json.Unmarshal(data, status); err != nil {
+			status = nil
+		}
+	}
+	next := healthState(status, err)
+
+	w.mu.Lock()
+	prev := w.state
+	w.state = next
+	w.mu.Unlock()
+
+	if next != prev && w.onChange != nil {
+		w.onChange(prev, next, status)
+	}
+}
+
+// State returns the state seen by the latest check.
+func (w *HealthWatcher) State() HealthState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// Stop stops watching. It may be called from onChange.
+func (w *HealthWatcher) Stop() {
+	w.client.untrack(w)
+	w.cancel()
+}
+
+func (w *HealthWatcher) drain(ctx context.Context) error {
+	w.Stop()
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package aimesh
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultHedgeAfter is the hedging delay used until enough reads have been
+// timed to estimate their p95 latency.
+const defaultHedgeAfter = 100 * time.Millisecond
+
+// WithHedging sends a second attempt of a read if the first has not
+// answered after the given delay, preferring a different broker, and
+// returns whichever succeeds first. A zero delay waits for the p95 latency
+// of the client's recent reads. It cuts tail latency when one broker replica
+// is slow, at the cost of extra load. Hedging is for idempotent reads such
+// as GetBudget, ListEndpoints and HealthCheck only: it applies to GET calls
+// and never to Receive, subscriptions or other reads that consume messages.
+func WithHedging(after time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.hedge = true
+		o.hedgeAfter = after
+	}
+}
+
+// latencyWindow keeps the latencies of the most recent successful reads.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [128]time.Duration
+	n       int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.n%len(w.samples)] = d
+	w.n++
+}
+
+// p95 returns the 95th percentile latency, or defaultHedgeAfter while there
+// are fewer than 20 samples.
+func (w *latencyWindow) p95() time.Duration {
+	w.mu.Lock()
+	n := w.n
+	if n > len(w.samples) {
+		n = len(w.samples)
+	}
+	sorted := append([]time.Duration(nil), w.samples[:n]...)
+	w.mu.Unlock()
+
+	if n < 20 {
+		return defaultHedgeAfter
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[n*95/100]
+}
+
+// sendHedged makes one attempt of a read, starting a second one on the next
+// broker if the first is slower than the hedging delay.
+func (c *Client) sendHedged(ctx context.Context, method, path string, data []byte, o *callOptions) ([]byte, error) {
+	delay := o.hedgeAfter
+	if delay <= 0 {
+		delay = c.reads.p95()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		body []byte
+		err  error
+	}
+	results := make(chan result, 2)
+	bases := c.balancer.Pick()
+	attempt := func(bases []string) {
+		body, err := c.sendTo(ctx, bases, method, path, data, o)
+		results <- result{body, err}
+	}
+	go attempt(bases)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending, hedged := 1, false
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				pending++
+				if len(bases) > 1 {
+					bases = append(bases[1:len(bases):len(bases)], bases[0])
+				}
+				go attempt(bases)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil || pending == 0 {
+				return r.body, r.err
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package aimesh
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JWTIdentity is a TokenSource that mints self-issued JWTs identifying an
+// agent, signed with a key registered through RegisterAgentKey. Use it as
+// ClientConfig.TokenSource; tokens are renewed shortly before they expire.
+type JWTIdentity struct {
+	AgentID string
+	// KeyID names the registered key. It is sent as the kid header.
+	KeyID string
+	// Key signs the tokens: an ECDSA (ES256/384/512), RSA (RS256) or
+	// Ed25519 (EdDSA) private key, or any crypto.Signer backed by one,
+	// such as a KMS key.
+	Key crypto.Signer
+	// Audience is the aud claim. Defaults to "aimesh".
+	Audience string
+	// TTL is how long each token is valid. Defaults to five minutes.
+	TTL time.Duration
+	// Claims are added to every token.
+	Claims map[string]interface{}
+}
+
+// Token implements TokenSource.
+func (j *JWTIdentity) Token() (*Token, error) {
+	alg, sign, err := jwtSigner(j.Key)
+	if err != nil {
+		return nil, err
+	}
+	ttl := j.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	audience := j.Audience
+	if audience == "" {
+		audience = "aimesh"
+	}
+
+	now := time.Now()
+	expiry := now.Add(ttl)
+	claims := make(map[string]interface{}, len(j.Claims)+6)
+	for k, v := range j.Claims {
+		claims[k] = v
+	}
+	claims["iss"] = j.AgentID
+	claims["sub"] = j.AgentID
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiry.Unix()
+	claims["jti"] = uuid.New().String()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": j.KeyID})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := sign([]byte(signingInput))
+	if err != nil {
+		return nil, fmt.Errorf("sign token: %w", err)
+	}
+
+	return &Token{
+		AccessToken: signingInput + "." + base64.RawURLEncoding.EncodeToString(signature),
+		Expiry:      expiry,
+	}, nil
+}
+
+// jwtSigner returns the JWS algorithm for key and a function producing
+// signatures in JWS format.
+func jwtSigner(key crypto.Signer) (string, func([]byte) ([]byte, error), error) {
+	if key == nil {
+		return "", nil, fmt.Errorf("%w: JWT identity needs a signing key", ErrValidation)
+	}
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		var alg string
+		var hash crypto.Hash
+		switch pub.Curve.Params().BitSize {
+		case 256:
+			alg, hash = "ES256", crypto.SHA256
+		case 384:
+			alg, hash = "ES384", crypto.SHA384
+		case 521:
+			alg, hash = "ES512", crypto.SHA512
+		default:
+			return "", nil, fmt.Errorf("%w: unsupported ECDSA curve %s", ErrValidation, pub.Curve.Params().Name)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return alg, func(data []byte) ([]byte, error) {
+			h := hash.New()
+			h.Write(data)
+			der, err := key.Sign(rand.Reader, h.Sum(nil), hash)
+			if err != nil {
+				return nil, err
+			}
+			// JWS wants r and s concatenated rather than ASN.1 encoded.
+			var sig struct{ R, S *big.Int }
+			if _, err := asn1.Unmarshal(der, &sig); err != nil {
+				return nil, err
+			}
+			out := make([]byte, 2*size)
+			sig.R.FillBytes(out[:size])
+			sig.S.FillBytes(out[size:])
+			return out, nil
+		}, nil
+	case *rsa.PublicKey:
+		return "RS256", func(data []byte) ([]byte, error) {
+			h := crypto.SHA256.New()
+			h.Write(data)
+			return key.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+		}, nil
+	case ed25519.PublicKey:
+		return "EdDSA", func(data []byte) ([]byte, error) {
+			return key.Sign(rand.Reader, data, crypto.Hash(0))
+		}, nil
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported signing key %T", ErrValidation, pub)
+	}
+}
+
+// RegisterAgentKey registers the public key that verifies an agent's
+// self-issued JWTs under keyID.
+func (c *Client) RegisterAgentKey(agentID, keyID string, pub crypto.PublicKey, opts ...CallOption) error {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	_, err = c.request("POST", "/agents/"+agentID+"/keys", map[string]interface{}{
+		"key_id":     keyID,
+		"public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}, opts...)
+	return err
+}
+
+// RevokeAgentKey revokes a registered agent key. Tokens signed with it are
+// rejected from then on.
+func (c *Client) RevokeAgentKey(agentID, keyID string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/agents/"+agentID+"/keys/"+keyID, nil, opts...)
+	return err
+}
@@ -0,0 +1,260 @@
+package aimesh
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// In-cluster service account files.
+const (
+	serviceAccountDir   = "/var/run/secrets/kubernetes.io/serviceaccount/"
+	serviceAccountToken = serviceAccountDir + "token"
+	serviceAccountCA    = serviceAccountDir + "ca.crt"
+	serviceAccountNS    = serviceAccountDir + "namespace"
+)
+
+// KubernetesDiscovery tracks the ready pods behind a Kubernetes Service by
+// watching its EndpointSlices, so the client follows broker scale-ups,
+// scale-downs and rollouts without an external load balancer. It talks to
+// the API server directly and by default uses the pod's service account,
+// which needs permission to list and watch endpointslices.
+type KubernetesDiscovery struct {
+	// Service is the name of the broker Service.
+	Service string
+	// Namespace defaults to the pod's own namespace.
+	Namespace string
+	// PortName selects the endpoint port. Defaults to the first port.
+	PortName string
+	// Scheme is used for the broker URLs. Defaults to "http".
+	Scheme string
+
+	// APIServer defaults to the in-cluster address from
+	// KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT.
+	APIServer string
+	// TokenFile defaults to the service account token. It is re-read on
+	// every request so rotated tokens are picked up.
+	TokenFile string
+	// HTTPClient defaults to a client trusting the service account CA.
+	HTTPClient *http.Client
+
+	once    sync.Once
+	initErr error
+}
+
+type endpointSlice struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	} `json:"ports"`
+}
+
+type endpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []endpointSlice `json:"items"`
+}
+
+type endpointSliceEvent struct {
+	Type   string        `json:"type"`
+	Object endpointSlice `json:"object"`
+}
+
+func (k *KubernetesDiscovery) init() error {
+	k.once.Do(func() {
+		if k.Namespace == "" {
+			data, err := os.ReadFile(serviceAccountNS)
+			if err != nil {
+				k.initErr = fmt.Errorf("%w: no namespace configured and not running in a pod: %v", ErrValidation, err)
+				return
+			}
+			k.Namespace = strings.TrimSpace(string(data))
+		}
+		if k.APIServer == "" {
+			host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+			if host == "" || port == "" {
+				k.initErr = fmt.Errorf("%w: no API server configured and not running in a cluster", ErrValidation)
+				return
+			}
+			k.APIServer = "https://" + net.JoinHostPort(host, port)
+		}
+		if k.TokenFile == "" {
+			k.TokenFile = serviceAccountToken
+		}
+		if k.HTTPClient == nil {
+			pool := x509.NewCertPool()
+			if ca, err := os.ReadFile(serviceAccountCA); err == nil {
+				pool.AppendCertsFromPEM(ca)
+			}
+			k.HTTPClient = &http.Client{
+				Transport: &http.Transport{
+					Proxy:           http.ProxyFromEnvironment,
+					TLSClientConfig: &tls.Config{RootCAs: pool},
+				},
+			}
+		}
+	})
+	return k.initErr
+}
+
+// get issues an authenticated GET against the API server.
+func (k *KubernetesDiscovery) get(ctx context.Context, v url.Values) (*http.Response, error) {
+	v.Set("labelSelector", "kubernetes.io/service-name="+k.Service)
+	path := "/apis/discovery.k8s.io/v1/namespaces/" + k.Namespace + "/endpointslices"
+	req, err := http.NewRequestWithContext(ctx, "GET", withQuery(k.APIServer+path, v), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if token, err := os.ReadFile(k.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := k.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError(resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+// list fetches the Service's EndpointSlices.
+func (k *KubernetesDiscovery) list(ctx context.Context) (*endpointSliceList, error) {
+	if err := k.init(); err != nil {
+		return nil, err
+	}
+	resp, err := k.get(ctx, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list endpointSliceList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Brokers returns the URLs of the Service's ready endpoints.
+func (k *KubernetesDiscovery) Brokers(ctx context.Context) ([]string, error) {
+	list, err := k.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices := make(map[string]endpointSlice, len(list.Items))
+	for _, s := range list.Items {
+		slices[s.Metadata.Name] = s
+	}
+	return k.brokers(slices), nil
+}
+
+// Watch lists the Service's EndpointSlices and then follows changes to them.
+func (k *KubernetesDiscovery) Watch(ctx context.Context, update func(brokers []string)) error {
+	list, err := k.list(ctx)
+	if err != nil {
+		return err
+	}
+	slices := make(map[string]endpointSlice, len(list.Items))
+	for _, s := range list.Items {
+		slices[s.Metadata.Name] = s
+	}
+	update(k.brokers(slices))
+
+	resp, err := k.get(ctx, url.Values{
+		"watch":           {"true"},
+		"resourceVersion": {list.Metadata.ResourceVersion},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event endpointSliceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return err
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			slices[event.Object.Metadata.Name] = event.Object
+		case "DELETED":
+			delete(slices, event.Object.Metadata.Name)
+		case "ERROR":
+			return fmt.Errorf("%w: endpointslice watch for %s ended with an error event", ErrConnection, k.Service)
+		default:
+			continue
+		}
+		update(k.brokers(slices))
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+	return nil
+}
+
+// brokers returns the sorted URLs of the ready endpoints in slices.
+func (k *KubernetesDiscovery) brokers(slices map[string]endpointSlice) []string {
+	scheme := k.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range slices {
+		port := 0
+		for _, p := range s.Ports {
+			if k.PortName == "" || p.Name == k.PortName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, e := range s.Endpoints {
+			// A missing ready condition means ready.
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			for _, addr := range e.Addresses {
+				u := scheme + "://" + net.JoinHostPort(addr, strconv.Itoa(port))
+				if !seen[u] {
+					seen[u] = true
+					out = append(out, u)
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,126 @@
+package aimesh
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LocalityBalancer prefers brokers in the client's own region. Brokers of
+// other regions are only tried once every local broker has failed; a local
+// broker that fails is moved behind them for Cooldown. Stats reports how much
+// traffic went cross-region.
+type LocalityBalancer struct {
+	region   string
+	regionOf func(broker string) string
+	inner    Balancer
+
+	// Cooldown is how long a failed local broker yields to other regions.
+	// Defaults to 30 seconds.
+	Cooldown time.Duration
+
+	mu      sync.Mutex
+	demoted map[string]time.Time
+
+	local  atomic.Uint64
+	remote atomic.Uint64
+	failed atomic.Uint64
+}
+
+// LocalityStats counts requests by the region of the broker that served
+// them.
+type LocalityStats struct {
+	LocalRequests       uint64
+	CrossRegionRequests uint64
+	// LocalFailures counts requests a local broker failed, sending them on
+	// towards other regions.
+	LocalFailures uint64
+}
+
+// NewLocalityBalancer returns a balancer for a client in region. regionOf
+// maps a broker URL to its region; inner orders the brokers within each
+// region, and defaults to round-robin when nil.
+func NewLocalityBalancer(region string, regionOf func(broker string) string, inner Balancer) *LocalityBalancer {
+	if inner == nil {
+		inner = NewRoundRobinBalancer(nil)
+	}
+	return &LocalityBalancer{
+		region:   region,
+		regionOf: regionOf,
+		inner:    inner,
+		demoted:  make(map[string]time.Time),
+	}
+}
+
+// RegionMap returns a regionOf function for NewLocalityBalancer that looks
+// brokers up in regions.
+func RegionMap(regions map[string]string) func(broker string) string {
+	return func(broker string) string {
+		return regions[broker]
+	}
+}
+
+// Update replaces the brokers.
+func (l *LocalityBalancer) Update(brokers []string) {
+	l.inner.Update(brokers)
+}
+
+// Pick returns the healthy local brokers, then the other regions, then the
+// local brokers in cooldown.
+func (l *LocalityBalancer) Pick() []string {
+	cooldown := l.Cooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var local, remote, demoted []string
+	now := time.Now()
+	for _, b := range l.inner.Pick() {
+		switch {
+		case l.regionOf(b) != l.region:
+			remote = append(remote, b)
+		case now.Sub(l.demoted[b]) < cooldown:
+			demoted = append(demoted, b)
+		default:
+			local = append(local, b)
+		}
+	}
+	out := append(local, remote...)
+	return append(out, demoted...)
+}
+
+// Report records the outcome of a request to a broker.
+func (l *LocalityBalancer) Report(broker string, latency time.Duration, err error) {
+	l.inner.Report(broker, latency, err)
+
+	local := l.regionOf(broker) == l.region
+	if failsOver(err) {
+		if local {
+			l.failed.Add(1)
+			l.mu.Lock()
+			l.demoted[broker] = time.Now()
+			l.mu.Unlock()
+		}
+		return
+	}
+	if local {
+		l.local.Add(1)
+		l.mu.Lock()
+		delete(l.demoted, broker)
+		l.mu.Unlock()
+	} else {
+		l.remote.Add(1)
+	}
+}
+
+// Stats returns the request counts since the balancer was created.
+func (l *LocalityBalancer) Stats() LocalityStats {
+	return LocalityStats{
+		LocalRequests:       l.local.Load(),
+		CrossRegionRequests: l.remote.Load(),
+		LocalFailures:       l.failed.Load(),
+	}
+}
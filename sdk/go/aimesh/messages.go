@@ -0,0 +1,262 @@
+package aimesh
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FailureReason is a structured explanation of why a consumer could not
+// process a message. The broker records the latest one on the message.
+type FailureReason struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// NackOptions controls what happens to a negatively acknowledged message.
+type NackOptions struct {
+	// Requeue asks the broker to redeliver the message. When false the
+	// message is treated as permanently bad and dead-lettered.
+	Requeue bool
+	// Delay postpones redelivery of a requeued message.
+	Delay time.Duration
+	// MaxRedeliveries hints how many more deliveries the message should get
+	// before it is dead-lettered. Zero leaves the broker default in place.
+	MaxRedeliveries int
+	// Reason is recorded on the message as its LastFailure.
+	Reason *FailureReason
+}
+
+// CancelMessage withdraws a pending message before it is dispatched to an
+// endpoint. It returns ErrConflict if the message is already being processed
+// and ErrNotFound if the broker does not know the message.
+func (c *Client) CancelMessage(messageID string, opts ...CallOption) error {
+	_, err := c.request("POST", "/messages/"+messageID+"/cancel", nil, opts...)
+	return err
+}
+
+// RequeueOptions controls RequeueMessage.
+type RequeueOptions struct {
+	// AgentID moves the message to another agent's queue. Defaults to the
+	// message's own agent.
+	AgentID string
+	// Delay postpones delivery of the requeued message.
+	Delay time.Duration
+	// ResetDeliveryCount starts the message's redelivery budget over.
+	ResetDeliveryCount bool
+}
+
+// RequeueMessage puts a stuck message back at the tail of its queue, taking
+// it away from the consumer currently holding it. A nil opts requeues it for
+// immediate delivery to the same agent.
+func (c *Client) RequeueMessage(messageID string, opts *RequeueOptions, callOpts ...CallOption) error {
+	if opts == nil {
+		opts = &RequeueOptions{}
+	}
+	if opts.Delay < 0 {
+		return fmt.Errorf("%w: requeue delay must not be negative", ErrValidation)
+	}
+
+	body := map[string]interface{}{
+		"reset_delivery_count": opts.ResetDeliveryCount,
+	}
+	if opts.AgentID != "" {
+		body["agent_id"] = opts.AgentID
+	}
+	if opts.Delay > 0 {
+		body["delay_ms"] = opts.Delay.Milliseconds()
+	}
+
+	_, err := c.request("POST", "/messages/"+messageID+"/requeue", body, callOpts...)
+	return err
+}
+
+// SetMessagePriority changes the priority of a pending message in place. It
+// returns ErrConflict if the message is already being processed.
+func (c *Client) SetMessagePriority(messageID string, priority Priority, opts ...CallOption) error {
+	if !priority.Valid() {
+		return fmt.Errorf("%w: priority %d outside %d-%d", ErrValidation, priority, MinPriority, MaxPriority)
+	}
+	_, err := c.request("PUT", "/messages/"+messageID+"/priority", map[string]interface{}{
+		"priority": int(priority),
+	}, opts...)
+	return err
+}
+
+// CancelByTaskGraph withdraws every pending message belonging to a task graph
+// and returns how many messages were cancelled. Messages that are already
+// being processed are left alone.
+func (c *Client) CancelByTaskGraph(taskGraphID string, opts ...CallOption) (int, error) {
+	data, err := c.request("POST", "/messages/cancel", map[string]interface{}{
+		"task_graph_id": taskGraphID,
+	}, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Cancelled int `json:"cancelled"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Cancelled, nil
+}
+
+// Nack negatively acknowledges a received message. With opts.Requeue the
+// message is redelivered after opts.Delay; otherwise it is dead-lettered.
+// A nil opts dead-letters the message without a reason.
+func (c *Client) Nack(messageID string, opts *NackOptions, callOpts ...CallOption) error {
+	if opts == nil {
+		opts = &NackOptions{}
+	}
+
+	body := map[string]interface{}{
+		"requeue": opts.Requeue,
+	}
+	if opts.Delay > 0 {
+		body["delay_ms"] = opts.Delay.Milliseconds()
+	}
+	if opts.MaxRedeliveries > 0 {
+		body["max_redeliveries"] = opts.MaxRedeliveries
+	}
+	if opts.Reason != nil {
+		body["reason"] = opts.Reason
+	}
+
+	_, err := c.request("POST", "/messages/"+messageID+"/nack", body, callOpts...)
+	return err
+}
+
+// GetConversation gets every message in a conversation, oldest first.
+func (c *Client) GetConversation(conversationID string, opts ...CallOption) ([]Message, error) {
+	data, err := c.request("GET", "/conversations/"+conversationID, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Messages []Message `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Messages {
+		resp.Messages[i].decodePayload()
+	}
+
+	return resp.Messages, nil
+}
+
+// BroadcastOptions sets the fields shared by every message of a broadcast.
+type BroadcastOptions struct {
+	Priority     Priority
+	BudgetTokens float64
+	Deadline     time.Duration
+	TaskGraphID  string
+	TraceID      string
+	Metadata     map[string]string
+}
+
+// BroadcastResult is the outcome of a broadcast for a single agent. Exactly
+// one of Ack and Err is set.
+type BroadcastResult struct {
+	Ack *Acknowledgment
+	Err error
+}
+
+// Broadcast sends the same payload to every agent in agentIDs. The broker
+// fans the message out, so this is a single request regardless of the number
+// of agents. The returned map holds one result per agent; the error is only
+// non-nil if the request as a whole failed.
+func (c *Client) Broadcast(agentIDs []string, payload []byte, opts *BroadcastOptions, callOpts ...CallOption) (map[string]BroadcastResult, error) {
+	if len(agentIDs) == 0 {
+		return nil, fmt.Errorf("%w: broadcast needs at least one agent", ErrValidation)
+	}
+	if opts == nil {
+		opts = &BroadcastOptions{}
+	}
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	defer c.end()
+
+	shared := &Message{Payload: payload, Metadata: make(map[string]string, len(opts.Metadata))}
+	for k, v := range opts.Metadata {
+		shared.Metadata[k] = v
+	}
+	if err := c.process(shared); err != nil {
+		return nil, err
+	}
+
+	now := c.serverNow()
+	template := map[string]interface{}{
+		"payload":       hex.EncodeToString(shared.Payload),
+		"priority":      int(PriorityNormal),
+		"budget_tokens": float64(DefaultBudgetTokens),
+		"timestamp":     now.UnixNano(),
+	}
+	if opts.Priority != 0 {
+		if !opts.Priority.Valid() {
+			return nil, fmt.Errorf("%w: priority %d outside %d-%d", ErrValidation, opts.Priority, MinPriority, MaxPriority)
+		}
+		template["priority"] = int(opts.Priority)
+	}
+	if opts.BudgetTokens > 0 {
+		template["budget_tokens"] = opts.BudgetTokens
+	}
+	if opts.Deadline > 0 {
+		template["deadline_ms"] = now.Add(opts.Deadline).UnixMilli()
+	}
+	if opts.TaskGraphID != "" {
+		template["task_graph_id"] = opts.TaskGraphID
+	}
+	if opts.TraceID != "" {
+		template["trace_id"] = opts.TraceID
+	}
+	if len(shared.Metadata) > 0 {
+		template["metadata"] = shared.Metadata
+	}
+	if orgID := c.callOptions(callOpts).orgID; orgID != "" {
+		template["org_id"] = orgID
+	}
+
+	data, err := c.request("POST", "/messages/broadcast", map[string]interface{}{
+		"agent_ids": agentIDs,
+		"message":   template,
+	}, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Results map[string]struct {
+			Ack        json.RawMessage `json:"ack"`
+			StatusCode int             `json:"status_code"`
+			Error      string          `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]BroadcastResult, len(resp.Results))
+	for agentID, r := range resp.Results {
+		if r.Error != "" {
+			if r.StatusCode >= 400 {
+				results[agentID] = BroadcastResult{Err: newAPIError(r.StatusCode, []byte(r.Error))}
+			} else {
+				results[agentID] = BroadcastResult{Err: errors.New(r.Error)}
+			}
+			continue
+		}
+		ack, err := decodeAck(r.Ack)
+		results[agentID] = BroadcastResult{Ack: ack, Err: err}
+	}
+
+	return results, nil
+}
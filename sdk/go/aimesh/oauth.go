@@ -0,0 +1,104 @@
+package aimesh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryLeeway is how long before its expiry a token is refreshed.
+const tokenExpiryLeeway = 10 * time.Second
+
+// Token is a short-lived access token.
+type Token struct {
+	AccessToken string
+	// TokenType is the Authorization scheme. Defaults to Bearer.
+	TokenType string
+	// Expiry is when the token expires. A zero Expiry means the token is
+	// not cached: the TokenSource is asked again for every request.
+	Expiry time.Time
+}
+
+func (t *Token) valid() bool {
+	return t.AccessToken != "" && !t.Expiry.IsZero() && time.Until(t.Expiry) > tokenExpiryLeeway
+}
+
+func (t *Token) header() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// TokenSource supplies access tokens. Wrap a golang.org/x/oauth2
+// TokenSource with OAuth2.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to TokenSource.
+type TokenSourceFunc func() (*Token, error)
+
+// Token implements TokenSource.
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// OAuth2 adapts a golang.org/x/oauth2 TokenSource, or any source whose
+// tokens set their own Authorization header, without the SDK depending on
+// the oauth2 module:
+//
+//	aimesh.OAuth2(conf.TokenSource(ctx))
+//
+// The oauth2 source caches and refreshes tokens itself, so it is asked for
+// a token on every request.
+func OAuth2[T interface{ SetAuthHeader(*http.Request) }](ts interface{ Token() (T, error) }) TokenSource {
+	return TokenSourceFunc(func() (*Token, error) {
+		tok, err := ts.Token()
+		if err != nil {
+			return nil, err
+		}
+		req := &http.Request{Header: make(http.Header)}
+		tok.SetAuthHeader(req)
+		typ, access, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+		return &Token{AccessToken: access, TokenType: typ}, nil
+	})
+}
+
+// tokenAuth authorizes requests with tokens from a TokenSource, caching
+// each token until shortly before it expires.
+type tokenAuth struct {
+	source TokenSource
+
+	mu    sync.Mutex
+	token *Token
+}
+
+func (t *tokenAuth) authorization(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == nil || !t.token.valid() {
+		tok, err := t.source.Token()
+		if err != nil {
+			return "", fmt.Errorf("access token: %w", err)
+		}
+		t.token = tok
+	}
+	return t.token.header(), nil
+}
+
+func (t *tokenAuth) retry(ctx context.Context, sent string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tok, err := t.source.Token()
+	if err != nil {
+		t.token = nil
+		return "", false
+	}
+	t.token = tok
+	return tok.header(), tok.header() != sent
+}
@@ -0,0 +1,227 @@
+package aimesh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CallOption overrides client defaults for a single call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout     time.Duration
+	retries     int
+	header      http.Header
+	query       url.Values
+	poll        bool
+	auth        bool
+	hedge       bool
+	hedgeAfter  time.Duration
+	cacheTTL    time.Duration
+	noCache     bool
+	conditional bool
+	idempotent  bool
+	orgID       string
+	err         error
+}
+
+// WithTimeout bounds each attempt of the call by d instead of the client's
+// configured Timeout. A zero or negative d disables the timeout.
+func WithTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// WithRetries sets how many times the call is retried after a connection
+// error, a timed-out attempt, a rate limit or a 502, 503 or 504 response.
+// Calls that change state, such as sends, are only retried when the broker
+// certainly did not accept them, unless the message has a DedupContext.
+func WithRetries(n int) CallOption {
+	return func(o *callOptions) {
+		o.retries = n
+	}
+}
+
+// WithHeader adds a header to the call's request.
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// WithUserAgent replaces the call's User-Agent header, by default
+// "aimesh-go/" followed by the SDK Version.
+func WithUserAgent(userAgent string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set("User-Agent", userAgent)
+	}
+}
+
+// WithDefaultHeaders sets the headers in h on the call, replacing earlier
+// values of the same headers. Put it in ClientConfig.DefaultOptions to send
+// them on every call.
+func WithDefaultHeaders(h http.Header) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		for key, values := range h {
+			o.header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// WithAPIKey authenticates the call with key instead of the client's
+// credentials, for gateways that proxy many tenants through one client.
+func WithAPIKey(key string) CallOption {
+	return WithAuthHeader("Authorization", "Bearer "+key)
+}
+
+// WithAuthHeader authenticates the call with the given header instead of
+// the client's credentials, which are then not sent at all.
+func WithAuthHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+		o.auth = true
+	}
+}
+
+// WithOrgID scopes the call to an organization instead of the client's
+// ClientConfig.OrgID.
+func WithOrgID(orgID string) CallOption {
+	return func(o *callOptions) {
+		o.orgID = orgID
+	}
+}
+
+// WithSelector filters a list call, such as ListEndpoints, by labels. The
+// selector is a comma-separated list of key=value and key!=value terms that
+// must all hold, e.g. "gpu=a100,region=eu".
+func WithSelector(selector string) CallOption {
+	return func(o *callOptions) {
+		if err := validateSelector(selector); err != nil {
+			o.err = err
+			return
+		}
+		if o.query == nil {
+			o.query = make(url.Values)
+		}
+		o.query.Set("selector", selector)
+	}
+}
+
+// validateSelector checks the syntax of a label selector.
+func validateSelector(selector string) error {
+	for _, term := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(term, "!=")
+		if !ok {
+			key, value, ok = strings.Cut(term, "=")
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" || strings.ContainsAny(key+value, "=!") {
+			return fmt.Errorf("%w: invalid selector term %q", ErrValidation, term)
+		}
+	}
+	return nil
+}
+
+func (c *Client) callOptions(opts []CallOption) *callOptions {
+	o := &callOptions{
+		timeout: c.timeout,
+		retries: c.maxRetries,
+		orgID:   c.orgID,
+	}
+	for _, opt := range c.defaults {
+		opt(o)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.orgID != "" {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set("X-AiMesh-Org", o.orgID)
+	}
+	return o
+}
+
+// retryable reports whether a failed attempt may be tried again.
+func retryable(err error) bool {
+	if errors.Is(err, ErrConnection) || errors.Is(err, ErrRateLimit) || errors.Is(err, ErrTimeout) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// resendable reports whether a failed attempt of a request may be sent
+// again, to the same broker or another. GETs may; other requests only when
+// the broker certainly did not accept them, or when o marks them
+// idempotent, so that a message is never processed twice.
+func resendable(method string, err error, o *callOptions) bool {
+	if method == http.MethodGet || o.idempotent {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable)
+}
+
+// idempotent is the internal option marking a request safe to resend, such
+// as a message the broker deduplicates.
+func idempotent(ok bool) CallOption {
+	return func(o *callOptions) {
+		o.idempotent = o.idempotent || ok
+	}
+}
+
+// retryBackoff returns the delay before retry attempt n, starting at 100ms
+// and doubling up to 5s.
+func retryBackoff(n int) time.Duration {
+	d := 100 * time.Millisecond << uint(n)
+	if d <= 0 || d > 5*time.Second {
+		d = 5 * time.Second
+	}
+	return d
+}
+
+// longPoll extends the call timeout by wait, so that a long poll is not cut
+// off while the broker is still holding the request open.
+func longPoll(wait time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.poll = true
+		if o.timeout > 0 {
+			o.timeout += wait
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package aimesh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProbeReport summarizes a Probe run.
+type ProbeReport struct {
+	EndpointID string
+	Sent       int
+	Errors     int
+	ErrorRate  float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	// TokensPerSecond is the tokens used by successful probes divided by
+	// their total latency.
+	TokensPerSecond float64
+	// LastError is the most recent failure, if any.
+	LastError error
+}
+
+// Probe sends n copies of sample directly to an endpoint, one after the
+// other, and reports latency percentiles, error rate and throughput, so a new
+// endpoint can be validated before it receives real traffic. Each copy gets
+// a fresh message ID and is tagged with the "probe" metadata key.
+func (c *Client) Probe(endpointID string, sample *Message, n int, opts ...CallOption) (*ProbeReport, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: probe count must be positive", ErrValidation)
+	}
+
+	report := &ProbeReport{EndpointID: endpointID}
+	var latencies []time.Duration
+	var tokens float64
+	var busy time.Duration
+	for i := 0; i < n; i++ {
+		msg := *sample
+		msg.MessageID = uuid.New().String()
+		msg.TargetEndpoint = endpointID
+		msg.Metadata = make(map[string]string, len(sample.Metadata)+1)
+		for k, v := range sample.Metadata {
+			msg.Metadata[k] = v
+		}
+		msg.Metadata["probe"] = "true"
+
+		start := time.Now()
+		ack, err := c.SendMessage(&msg, opts...)
+		latency := time.Since(start)
+		report.Sent++
+		if err == nil && !ack.IsSuccess() {
+			err = fmt.Errorf("probe %s: status %s: %s", msg.MessageID, ack.Status, ack.Error)
+		}
+		if err != nil {
+			report.Errors++
+			report.LastError = err
+			continue
+		}
+		latencies = append(latencies, latency)
+		tokens += ack.TokensUsed
+		busy += latency
+	}
+
+	report.ErrorRate = float64(report.Errors) / float64(report.Sent)
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.P50 = percentile(latencies, 0.50)
+		report.P90 = percentile(latencies, 0.90)
+		report.P99 = percentile(latencies, 0.99)
+		report.Max = latencies[len(latencies)-1]
+	}
+	if busy > 0 {
+		report.TokensPerSecond = tokens / busy.Seconds()
+	}
+	return report, nil
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,77 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ReadinessCheck is one dependency the broker checks before accepting
+// messages, such as its store or queue.
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Readiness reports whether the broker can accept messages.
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks,omitempty"`
+}
+
+// Liveness reports whether the broker process is up, regardless of whether
+// it can accept messages.
+type Liveness struct {
+	Live       bool  `json:"live"`
+	UptimeSecs int64 `json:"uptime_secs"`
+}
+
+// Ready checks whether the broker can accept messages. A broker that answers
+// 503 is reported as not ready rather than as an error, so the result can
+// back a readiness probe directly. The call is not retried unless opts ask
+// for it.
+func (c *Client) Ready(opts ...CallOption) (*Readiness, error) {
+	var r Readiness
+	ok, err := c.probe("/health/ready", &r, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.Ready = ok
+	return &r, nil
+}
+
+// Live checks whether the broker process is up. A broker that answers 503 is
+// reported as not live rather than as an error. The call is not retried
+// unless opts ask for it.
+func (c *Client) Live(opts ...CallOption) (*Liveness, error) {
+	var l Liveness
+	ok, err := c.probe("/health/live", &l, opts)
+	if err != nil {
+		return nil, err
+	}
+	l.Live = ok
+	return &l, nil
+}
+
+// probe gets a health endpoint into v. It reports false without an error
+// when the broker answers 503, decoding the body if it has one.
+func (c *Client) probe(path string, v interface{}, opts []CallOption) (bool, error) {
+	opts = append([]CallOption{WithRetries(0)}, opts...)
+	data, err := c.request("GET", path, nil, opts...)
+	ok := err == nil
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable {
+		data, err = apiErr.Body, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil && ok {
+			return false, err
+		}
+	}
+	return ok, nil
+}
@@ -0,0 +1,223 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// progressPollInterval is how often SendAsync polls for progress when the
+// broker cannot stream it.
+const progressPollInterval = time.Second
+
+// Progress is an update from the agent processing a long-running message.
+type Progress struct {
+	MessageID string `json:"message_id"`
+	// Percent is how much of the work is done, from 0 to 100.
+	Percent float64 `json:"percent"`
+	// Stage names the current step, such as "retrieval" or "generation".
+	Stage       string `json:"stage,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+	TimestampMs int64  `json:"timestamp_ms"`
+}
+
+// ReportProgress tells the producer of a message how far its processing
+// has come. Agents call it from their handlers; producers see it through
+// SendAsync.
+func (c *Client) ReportProgress(messageID string, progress *Progress, opts ...CallOption) error {
+	if progress.Percent < 0 || progress.Percent > 100 {
+		return fmt.Errorf("%w: progress percent must be between 0 and 100", ErrValidation)
+	}
+	p := *progress
+	p.MessageID = messageID
+	if p.TimestampMs == 0 {
+		p.TimestampMs = time.Now().UnixMilli()
+	}
+	_, err := c.request("POST", "/messages/"+messageID+"/progress", &p, opts...)
+	return err
+}
+
+// GetProgress gets the latest progress reported for a message.
+func (c *Client) GetProgress(messageID string, opts ...CallOption) (*Progress, error) {
+	return c.getProgress(context.Background(), messageID, opts...)
+}
+
+func (c *Client) getProgress(ctx context.Context, messageID string, opts ...CallOption) (*Progress, error) {
+	data, err := c.requestContext(ctx, "GET", "/messages/"+messageID+"/progress", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Progress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// InFlight is a message sent with SendAsync that is still being processed.
+type InFlight struct {
+	messageID string
+	progress  chan Progress
+	done      chan struct{}
+	cancel    context.CancelFunc
+
+	mu  sync.Mutex
+	ack *Acknowledgment
+	err error
+}
+
+// SendAsync sends a message in the background and returns a handle that
+// delivers the agent's progress updates while the message is processed.
+// Progress is streamed when the broker advertises FeatureStreaming and
+// polled otherwise.
+func (c *Client) SendAsync(ctx context.Context, msg *Message, opts ...CallOption) (*InFlight, error) {
+	if o := c.callOptions(opts); o.err != nil {
+		return nil, o.err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f := &InFlight{
+		messageID: msg.MessageID,
+		progress:  make(chan Progress, 16),
+		done:      make(chan struct{}),
+		cancel:    cancel,
+	}
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		c.watchProgress(watchCtx, msg.MessageID, opts, f.update)
+	}()
+	go func() {
+		ack, err := c.SendMessageContext(ctx, msg, opts...)
+		stopWatch()
+		<-watched
+		f.mu.Lock()
+		f.ack, f.err = ack, err
+		f.mu.Unlock()
+		close(f.progress)
+		close(f.done)
+		cancel()
+	}()
+	return f, nil
+}
+
+// MessageID returns the ID of the message.
+func (f *InFlight) MessageID() string {
+	return f.messageID
+}
+
+// Progress returns the channel of progress updates. It is closed once the
+// message has been processed. When updates are not consumed quickly enough
+// the oldest are dropped.
+func (f *InFlight) Progress() <-chan Progress {
+	return f.progress
+}
+
+// Done is closed once the message has been processed or the send failed.
+func (f *InFlight) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the message's acknowledgment. Cancelling ctx stops the
+// wait, not the send; use Cancel for that.
+func (f *InFlight) Wait(ctx context.Context) (*Acknowledgment, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ack, f.err
+}
+
+// Cancel abandons the send.
+func (f *InFlight) Cancel() {
+	f.cancel()
+}
+
+// update delivers p, dropping the oldest pending update if the channel is
+// full.
+func (f *InFlight) update(p Progress) {
+	for {
+		select {
+		case f.progress <- p:
+			return
+		default:
+		}
+		select {
+		case <-f.progress:
+		default:
+		}
+	}
+}
+
+// watchProgress relays a message's progress updates to deliver until ctx is
+// done.
+func (c *Client) watchProgress(ctx context.Context, messageID string, opts []CallOption, deliver func(Progress)) {
+	if !c.supports(ctx, FeatureStreaming) {
+		c.pollProgress(ctx, messageID, opts, deliver)
+		return
+	}
+	o := c.callOptions(opts)
+	path := "/messages/" + messageID + "/progress/watch"
+	lastID := ""
+	for attempt := 0; ctx.Err() == nil; attempt++ {
+		resp, err := c.openStream(ctx, http.MethodGet, path, nil, o, lastID)
+		if err == nil {
+			attempt = 0
+			lastID = c.forwardProgress(resp, deliver, lastID)
+		}
+		sleepContext(ctx, retryBackoff(attempt))
+	}
+}
+
+// forwardProgress relays updates from one stream until it ends. It returns
+// the ID of the last event for resuming.
+func (c *Client) forwardProgress(resp *http.Response, deliver func(Progress), lastID string) string {
+	defer resp.Body.Close()
+	r := newSSEReader(resp.Body)
+	for {
+		ev, err := r.next()
+		if err != nil {
+			return lastID
+		}
+		if ev.ID != "" {
+			lastID = ev.ID
+		}
+		var p Progress
+		if err := json.Unmarshal([]byte(ev.Data), &p); err != nil {
+			continue
+		}
+		deliver(p)
+	}
+}
+
+// pollProgress polls for a message's progress until ctx is done, delivering
+// each new update.
+func (c *Client) pollProgress(ctx context.Context, messageID string, opts []CallOption, deliver func(Progress)) {
+	opts = append(opts[:len(opts):len(opts)], WithTimeout(progressPollInterval), WithRetries(0))
+	ticker := time.NewTicker(progressPollInterval)
+	defer ticker.Stop()
+
+	var last Progress
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		p, err := c.getProgress(ctx, messageID, opts...)
+		if err != nil || *p == last {
+			continue
+		}
+		last = *p
+		deliver(*p)
+	}
+}
@@ -0,0 +1,274 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// QueuePolicy configures how the broker orders an agent's queue.
+//
+// Priority aging prevents starvation: a waiting message gains AgingRate
+// priority points per minute until it reaches AgingCeiling.
+type QueuePolicy struct {
+	AgingRate    float64  `json:"aging_rate_per_min"`
+	AgingCeiling Priority `json:"aging_ceiling"`
+}
+
+// Validate checks the policy for values the broker would reject.
+func (p *QueuePolicy) Validate() error {
+	if p.AgingRate < 0 {
+		return fmt.Errorf("%w: aging rate must not be negative", ErrValidation)
+	}
+	if !p.AgingCeiling.Valid() {
+		return fmt.Errorf("%w: aging ceiling %d outside %d-%d",
+			ErrValidation, p.AgingCeiling, MinPriority, MaxPriority)
+	}
+	return nil
+}
+
+// SetQueuePolicy sets the queue policy for an agent.
+func (c *Client) SetQueuePolicy(agentID string, policy *QueuePolicy, opts ...CallOption) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	_, err := c.request("PUT", "/agents/"+agentID+"/queue-policy", policy, opts...)
+	return err
+}
+
+// GetQueuePolicy gets the queue policy for an agent.
+func (c *Client) GetQueuePolicy(agentID string, opts ...CallOption) (*QueuePolicy, error) {
+	data, err := c.request("GET", "/agents/"+agentID+"/queue-policy", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy QueuePolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// PurgeOptions selects the messages PurgeQueue deletes. Filters combine; an
+// empty PurgeOptions purges the whole queue.
+type PurgeOptions struct {
+	// OlderThan only purges messages enqueued more than this long ago.
+	OlderThan time.Duration
+	// PriorityBelow only purges messages with a lower priority.
+	PriorityBelow Priority
+	// TaskGraphID only purges messages of this task graph.
+	TaskGraphID string
+	// DryRun reports how many messages would be purged without deleting
+	// them.
+	DryRun bool
+}
+
+// PurgeQueue deletes pending messages from an agent's queue and returns how
+// many were deleted, or with opts.DryRun how many would be. Messages already
+// being processed are left alone.
+func (c *Client) PurgeQueue(agentID string, opts *PurgeOptions, callOpts ...CallOption) (int, error) {
+	if opts == nil {
+		opts = &PurgeOptions{}
+	}
+	if opts.OlderThan < 0 {
+		return 0, fmt.Errorf("%w: purge age must not be negative", ErrValidation)
+	}
+
+	body := map[string]interface{}{
+		"dry_run": opts.DryRun,
+	}
+	if opts.OlderThan > 0 {
+		body["older_than_ms"] = opts.OlderThan.Milliseconds()
+	}
+	if opts.PriorityBelow != 0 {
+		if !opts.PriorityBelow.Valid() {
+			return 0, fmt.Errorf("%w: priority %d outside %d-%d", ErrValidation, opts.PriorityBelow, MinPriority, MaxPriority)
+		}
+		body["priority_below"] = int(opts.PriorityBelow)
+	}
+	if opts.TaskGraphID != "" {
+		body["task_graph_id"] = opts.TaskGraphID
+	}
+
+	data, err := c.request("POST", "/agents/"+agentID+"/queue/purge", body, callOpts...)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Purged int `json:"purged"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Purged, nil
+}
+
+// PendingOptions controls ListPending.
+type PendingOptions struct {
+	ListOptions
+	// IncludePayloads returns message payloads as well as metadata.
+	IncludePayloads bool
+}
+
+// PendingPage is one page of queued messages.
+type PendingPage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor"`
+}
+
+// ListPending lists the messages waiting in an agent's queue, in delivery
+// order, without consuming them. Pass the NextCursor of a page as
+// opts.Cursor to fetch the following page.
+func (c *Client) ListPending(agentID string, opts *PendingOptions, callOpts ...CallOption) (*PendingPage, error) {
+	if opts == nil {
+		opts = &PendingOptions{}
+	}
+	v := opts.values()
+	if opts.IncludePayloads {
+		v.Set("include_payloads", "true")
+	}
+
+	data, err := c.request("GET", withQuery("/agents/"+agentID+"/queue/messages", v), nil, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page PendingPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	for i := range page.Messages {
+		page.Messages[i].decodePayload()
+	}
+
+	return &page, nil
+}
+
+// PeekMessage returns the next message an agent would receive, with its
+// payload, without consuming it. It returns ErrNotFound if the queue is
+// empty.
+func (c *Client) PeekMessage(agentID string, opts ...CallOption) (*Message, error) {
+	page, err := c.ListPending(agentID, &PendingOptions{
+		ListOptions:     ListOptions{Limit: 1},
+		IncludePayloads: true,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Messages) == 0 {
+		return nil, fmt.Errorf("%w: queue of %s is empty", ErrNotFound, agentID)
+	}
+	return &page.Messages[0], nil
+}
+
+// QueueStats is the depth and throughput of an agent's queue.
+type QueueStats struct {
+	AgentID string
+	// Depth is the number of messages waiting for delivery.
+	Depth int64
+	// InFlight is the number of delivered but unacknowledged messages.
+	InFlight int64
+	// OldestAge is how long the oldest waiting message has been queued.
+	OldestAge time.Duration
+	// EnqueueRate and DequeueRate are messages per second over the last
+	// minute.
+	EnqueueRate float64
+	DequeueRate float64
+}
+
+// UnmarshalJSON decodes the stats from the broker's wire format.
+func (s *QueueStats) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		AgentID     string  `json:"agent_id"`
+		Depth       int64   `json:"depth"`
+		InFlight    int64   `json:"in_flight"`
+		OldestAgeMs int64   `json:"oldest_age_ms"`
+		EnqueueRate float64 `json:"enqueue_rate"`
+		DequeueRate float64 `json:"dequeue_rate"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*s = QueueStats{
+		AgentID:     wire.AgentID,
+		Depth:       wire.Depth,
+		InFlight:    wire.InFlight,
+		OldestAge:   time.Duration(wire.OldestAgeMs) * time.Millisecond,
+		EnqueueRate: wire.EnqueueRate,
+		DequeueRate: wire.DequeueRate,
+	}
+	return nil
+}
+
+// GetQueueStats gets the depth, lag and throughput of an agent's queue.
+func (c *Client) GetQueueStats(agentID string, opts ...CallOption) (*QueueStats, error) {
+	data, err := c.request("GET", "/agents/"+agentID+"/queue/stats", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats QueueStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// TopQueues orderings.
+const (
+	TopByDepth     = "depth"
+	TopByTokenBurn = "token_burn"
+)
+
+// QueueRank is one agent in a TopQueues report.
+type QueueRank struct {
+	AgentID string `json:"agent_id"`
+	Depth   int64  `json:"depth"`
+	// TokenBurn is the tokens the agent consumed over the report window.
+	TokenBurn float64 `json:"token_burn"`
+}
+
+// TopQueues returns the n agents with the deepest queues (TopByDepth) or
+// the highest token burn over window (TopByTokenBurn), largest first. A
+// zero window uses the broker's default of one hour.
+func (c *Client) TopQueues(n int, by string, window time.Duration, opts ...CallOption) ([]QueueRank, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: top queue count must be positive", ErrValidation)
+	}
+	switch by {
+	case TopByDepth, TopByTokenBurn:
+	default:
+		return nil, fmt.Errorf("%w: unknown queue ordering %q", ErrValidation, by)
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("%w: window must not be negative", ErrValidation)
+	}
+
+	v := url.Values{
+		"n":  {strconv.Itoa(n)},
+		"by": {by},
+	}
+	if window > 0 {
+		v.Set("window_ms", strconv.FormatInt(window.Milliseconds(), 10))
+	}
+	data, err := c.request("GET", withQuery("/queues/top", v), nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Queues []QueueRank `json:"queues"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Queues, nil
+}
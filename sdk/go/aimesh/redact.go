@@ -0,0 +1,214 @@
+package aimesh
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PayloadProcessor rewrites outgoing messages before they leave the
+// process, for example to redact sensitive data. Set it as
+// ClientConfig.PayloadProcessor.
+type PayloadProcessor interface {
+	ProcessPayload(msg *Message) error
+}
+
+// PayloadProcessorFunc adapts a function to PayloadProcessor.
+type PayloadProcessorFunc func(msg *Message) error
+
+// ProcessPayload implements PayloadProcessor.
+func (f PayloadProcessorFunc) ProcessPayload(msg *Message) error {
+	return f(msg)
+}
+
+// process runs the client's payload processor on msg and re-encodes the
+// payload for the wire.
+func (c *Client) process(msg *Message) error {
+	if c.processor == nil {
+		return nil
+	}
+	if msg.Payload == nil {
+		msg.decodePayload()
+	}
+	if err := c.processor.ProcessPayload(msg); err != nil {
+		return err
+	}
+	msg.PayloadHex = hex.EncodeToString(msg.Payload)
+	return nil
+}
+
+// Detector finds sensitive data in a payload.
+type Detector interface {
+	// Name labels the kind of data found, such as "email".
+	Name() string
+	// Find returns the [start, end) byte offsets of each match.
+	Find(payload []byte) [][]int
+}
+
+// RegexpDetector detects matches of a regular expression.
+type RegexpDetector struct {
+	Label   string
+	Pattern *regexp.Regexp
+}
+
+// Name implements Detector.
+func (d *RegexpDetector) Name() string {
+	return d.Label
+}
+
+// Find implements Detector.
+func (d *RegexpDetector) Find(payload []byte) [][]int {
+	return d.Pattern.FindAllIndex(payload, -1)
+}
+
+// Built-in detectors.
+var (
+	EmailDetector = &RegexpDetector{
+		Label:   "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+	PhoneDetector = &RegexpDetector{
+		Label: "phone",
+		// A phone number needs a +country code or at least two
+		// separators, so that timestamps and IDs are left alone.
+		Pattern: regexp.MustCompile(`\+\d{1,3}[\s.\-]?(?:\(\d{1,4}\)|\d{1,4})(?:[\s.\-]?\d{2,4}){2,3}\b` +
+			`|(?:\(\d{2,4}\)[\s.\-]?|\b\d{2,4}[\s.\-])\d{3,4}[\s.\-]\d{3,4}\b`),
+	}
+	SecretDetector = &RegexpDetector{
+		Label: "secret",
+		Pattern: regexp.MustCompile(`\b(?:sk|pk|rk)[-_][A-Za-z0-9_\-]{16,}|\bAKIA[0-9A-Z]{16}\b|\bgh[pousr]_[A-Za-z0-9]{36}\b` +
+			`|\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`),
+	}
+)
+
+// Redactor is a PayloadProcessor that masks sensitive data. What was
+// redacted is recorded in the message's "redacted" metadata, such as
+// "email:2,phone:1".
+type Redactor struct {
+	// Detectors find the data to mask. Defaults to EmailDetector,
+	// PhoneDetector and SecretDetector.
+	Detectors []Detector
+	// Mask returns the replacement for a match. Defaults to
+	// [REDACTED:<name>]. In a JSON payload, the mask is escaped to keep the
+	// JSON valid, and a replaced value outside a string, such as a number,
+	// is quoted.
+	Mask func(name string, match []byte) []byte
+}
+
+// ProcessPayload implements PayloadProcessor.
+func (r *Redactor) ProcessPayload(msg *Message) error {
+	detectors := r.Detectors
+	if detectors == nil {
+		detectors = []Detector{EmailDetector, PhoneDetector, SecretDetector}
+	}
+	mask := r.Mask
+	if mask == nil {
+		mask = func(name string, match []byte) []byte {
+			return []byte("[REDACTED:" + name + "]")
+		}
+	}
+
+	type match struct {
+		start, end int
+		name       string
+	}
+	var matches []match
+	for _, d := range detectors {
+		for _, loc := range d.Find(msg.Payload) {
+			matches = append(matches, match{loc[0], loc[1], d.Name()})
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	// Earlier and then longer matches win overlaps.
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	var strs [][2]int
+	isJSON := json.Valid(msg.Payload)
+	if isJSON {
+		strs = jsonStrings(msg.Payload)
+	}
+
+	var out bytes.Buffer
+	counts := make(map[string]int)
+	pos := 0
+	for _, m := range matches {
+		if m.start < pos {
+			continue
+		}
+		out.Write(msg.Payload[pos:m.start])
+		masked := mask(m.name, msg.Payload[m.start:m.end])
+		if isJSON {
+			masked = jsonQuote(masked, !inSpans(strs, m.start))
+		}
+		out.Write(masked)
+		counts[m.name]++
+		pos = m.end
+	}
+	out.Write(msg.Payload[pos:])
+	msg.Payload = out.Bytes()
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	summary := make([]string, len(names))
+	for i, name := range names {
+		summary[i] = name + ":" + strconv.Itoa(counts[name])
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]string)
+	}
+	msg.Metadata["redacted"] = strings.Join(summary, ",")
+	return nil
+}
+
+// jsonStrings returns the spans of the string literals in a valid JSON
+// document, quotes included.
+func jsonStrings(doc []byte) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(doc); i++ {
+		if doc[i] != '"' {
+			continue
+		}
+		start := i
+		for i++; i < len(doc) && doc[i] != '"'; i++ {
+			if doc[i] == '\\' {
+				i++
+			}
+		}
+		spans = append(spans, [2]int{start, i + 1})
+	}
+	return spans
+}
+
+// jsonQuote encodes s as a JSON string, without the surrounding quotes
+// unless quoted is set.
+func jsonQuote(s []byte, quoted bool) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(string(s))
+	out := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if !quoted {
+		out = out[1 : len(out)-1]
+	}
+	return out
+}
+
+// inSpans reports whether pos falls inside one of the sorted spans.
+func inSpans(spans [][2]int, pos int) bool {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i][1] > pos })
+	return i < len(spans) && spans[i][0] <= pos
+}
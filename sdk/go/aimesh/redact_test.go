@@ -0,0 +1,83 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactorIgnoresNumbers(t *testing.T) {
+	payload := `{"ts":1697040000,"order":12345678,"id":"884422119933"}`
+	msg := NewMessage("agent", []byte(payload))
+	if err := (&Redactor{}).ProcessPayload(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(msg.Payload); got != payload {
+		t.Errorf("payload = %s, want it unchanged", got)
+	}
+	if _, ok := msg.Metadata["redacted"]; ok {
+		t.Errorf("redacted metadata set: %q", msg.Metadata["redacted"])
+	}
+}
+
+func TestRedactorPhoneNumbers(t *testing.T) {
+	for _, phone := range []string{"+1 555 123 4567", "+44 20 7946 0958", "(555) 123-4567", "555-123-4567", "555.123.4567"} {
+		msg := NewMessage("agent", []byte("call me at "+phone+" today"))
+		if err := (&Redactor{}).ProcessPayload(msg); err != nil {
+			t.Fatal(err)
+		}
+		if want := "call me at [REDACTED:phone] today"; string(msg.Payload) != want {
+			t.Errorf("%s: payload = %q, want %q", phone, msg.Payload, want)
+		}
+	}
+}
+
+func TestRedactorKeepsJSONValid(t *testing.T) {
+	order := &RegexpDetector{Label: "order", Pattern: regexp.MustCompile(`\b\d{8}\b`)}
+	msg := NewMessage("agent", []byte(`{"order":12345678,"note":"order 87654321","phone":"+1 555 123 4567"}`))
+	r := &Redactor{Detectors: []Detector{order, PhoneDetector}}
+	if err := r.ProcessPayload(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(msg.Payload) {
+		t.Fatalf("payload is not valid JSON: %s", msg.Payload)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(msg.Payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"order": "[REDACTED:order]",
+		"note":  "order [REDACTED:order]",
+		"phone": "[REDACTED:phone]",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if !strings.Contains(msg.Metadata["redacted"], "order:2") {
+		t.Errorf("redacted metadata = %q", msg.Metadata["redacted"])
+	}
+}
+
+func TestRedactorEscapesMaskInJSON(t *testing.T) {
+	msg := NewMessage("agent", []byte(`{"email":"a@example.com","phone":"+1 555 123 4567"}`))
+	r := &Redactor{Mask: func(name string, match []byte) []byte {
+		return []byte(`<"` + name + `"\>`)
+	}}
+	if err := r.ProcessPayload(msg); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(msg.Payload, &got); err != nil {
+		t.Fatalf("payload is not valid JSON: %s: %v", msg.Payload, err)
+	}
+	if want := `<"email"\>`; got["email"] != want {
+		t.Errorf("email = %q, want %q", got["email"], want)
+	}
+	if want := `<"phone"\>`; got["phone"] != want {
+		t.Errorf("phone = %q, want %q", got["phone"], want)
+	}
+}
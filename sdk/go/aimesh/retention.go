@@ -0,0 +1,115 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeleteMessagesByAgent permanently deletes every stored message addressed
+// to an agent, with its acknowledgments and dead letters, and returns how
+// many messages were deleted.
+func (c *Client) DeleteMessagesByAgent(agentID string, opts ...CallOption) (int, error) {
+	if agentID == "" {
+		return 0, fmt.Errorf("%w: agent ID is required", ErrValidation)
+	}
+	return c.deleteMessages(map[string]interface{}{"agent_id": agentID}, opts)
+}
+
+// DeleteByMetadata permanently deletes every stored message whose metadata
+// contains all of match, such as {"user_id": "u-42"} for a data subject's
+// erasure request, with its acknowledgments and dead letters. It returns
+// how many messages were deleted.
+func (c *Client) DeleteByMetadata(match map[string]string, opts ...CallOption) (int, error) {
+	if len(match) == 0 {
+		return 0, fmt.Errorf("%w: metadata match must not be empty", ErrValidation)
+	}
+	return c.deleteMessages(map[string]interface{}{"metadata": match}, opts)
+}
+
+func (c *Client) deleteMessages(body map[string]interface{}, opts []CallOption) (int, error) {
+	data, err := c.request("POST", "/messages/delete", body, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Deleted, nil
+}
+
+// RetentionPolicy bounds how long the broker stores data. Zero keeps data
+// until it is deleted explicitly.
+type RetentionPolicy struct {
+	// Messages is how long processed messages are kept.
+	Messages time.Duration
+	// Acknowledgments is how long acknowledgments and results are kept.
+	Acknowledgments time.Duration
+	// DeadLetters is how long dead-lettered messages are kept.
+	DeadLetters time.Duration
+}
+
+// MarshalJSON encodes the policy in the broker's wire format.
+func (p RetentionPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"messages_ms":        p.Messages.Milliseconds(),
+		"acknowledgments_ms": p.Acknowledgments.Milliseconds(),
+		"dead_letters_ms":    p.DeadLetters.Milliseconds(),
+	})
+}
+
+// UnmarshalJSON decodes the policy from the broker's wire format.
+func (p *RetentionPolicy) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		MessagesMs        int64 `json:"messages_ms"`
+		AcknowledgmentsMs int64 `json:"acknowledgments_ms"`
+		DeadLettersMs     int64 `json:"dead_letters_ms"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*p = RetentionPolicy{
+		Messages:        time.Duration(wire.MessagesMs) * time.Millisecond,
+		Acknowledgments: time.Duration(wire.AcknowledgmentsMs) * time.Millisecond,
+		DeadLetters:     time.Duration(wire.DeadLettersMs) * time.Millisecond,
+	}
+	return nil
+}
+
+// Validate checks the policy for values the broker would reject.
+func (p *RetentionPolicy) Validate() error {
+	if p.Messages < 0 || p.Acknowledgments < 0 || p.DeadLetters < 0 {
+		return fmt.Errorf("%w: retention periods must not be negative", ErrValidation)
+	}
+	return nil
+}
+
+// SetRetentionPolicy sets how long the broker stores messages,
+// acknowledgments and dead letters.
+func (c *Client) SetRetentionPolicy(policy *RetentionPolicy, opts ...CallOption) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	_, err := c.request("PUT", "/retention-policy", policy, opts...)
+	return err
+}
+
+// GetRetentionPolicy gets the broker's retention policy.
+func (c *Client) GetRetentionPolicy(opts ...CallOption) (*RetentionPolicy, error) {
+	data, err := c.request("GET", "/retention-policy", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy RetentionPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
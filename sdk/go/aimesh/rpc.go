@@ -0,0 +1,123 @@
+package aimesh
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Call sends msg and waits up to timeout for the reply. The reply-to address
+// and correlation ID are filled in automatically; the receiving agent answers
+// with Respond. Call returns ErrTimeout if no reply arrives in time.
+func (c *Client) Call(msg *Message, timeout time.Duration, opts ...CallOption) (*Message, error) {
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	defer c.end()
+
+	msg.ReplyTo = c.replies.inbox
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = msg.MessageID
+	}
+
+	ch := c.replies.wait(c, msg.CorrelationID)
+	defer c.replies.cancel(msg.CorrelationID)
+
+	if _, err := c.SendMessage(msg, opts...); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-ch:
+		return reply, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: no reply to %s after %v", ErrTimeout, msg.MessageID, timeout)
+	}
+}
+
+// Respond sends payload as the reply to a message sent with Call.
+func (c *Client) Respond(req *Message, payload []byte, opts ...CallOption) error {
+	if req.ReplyTo == "" {
+		return fmt.Errorf("%w: message %s has no reply-to address", ErrValidation, req.MessageID)
+	}
+
+	_, err := c.SendMessage(Reply(req, payload), opts...)
+	return err
+}
+
+// replyRouter receives replies on a per-client inbox and hands them to the
+// Call waiting for the matching correlation ID. The inbox is only polled
+// while at least one Call is waiting.
+type replyRouter struct {
+	inbox string
+
+	mu      sync.Mutex
+	waiters map[string]chan *Message
+	polling bool
+}
+
+func newReplyRouter() *replyRouter {
+	return &replyRouter{
+		inbox:   "inbox-" + uuid.New().String(),
+		waiters: make(map[string]chan *Message),
+	}
+}
+
+// wait registers a waiter for correlationID and starts polling if needed.
+func (r *replyRouter) wait(c *Client, correlationID string) <-chan *Message {
+	ch := make(chan *Message, 1)
+
+	r.mu.Lock()
+	r.waiters[correlationID] = ch
+	if !r.polling {
+		r.polling = true
+		go r.poll(c)
+	}
+	r.mu.Unlock()
+
+	return ch
+}
+
+func (r *replyRouter) cancel(correlationID string) {
+	r.mu.Lock()
+	delete(r.waiters, correlationID)
+	r.mu.Unlock()
+}
+
+// poll receives from the inbox until no waiters remain.
+func (r *replyRouter) poll(c *Client) {
+	for {
+		r.mu.Lock()
+		if len(r.waiters) == 0 {
+			r.polling = false
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+
+		msgs, err := c.Receive(r.inbox, &ReceiveOptions{MaxMessages: 10, WaitTime: 5 * time.Second})
+		if err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for i := range msgs {
+			reply := &msgs[i]
+			c.Ack(&Acknowledgment{OriginalMessageID: reply.MessageID, Status: StatusSuccess})
+
+			r.mu.Lock()
+			ch, ok := r.waiters[reply.CorrelationID]
+			r.mu.Unlock()
+			if ok {
+				select {
+				case ch <- reply:
+				default:
+				}
+			}
+		}
+	}
+}
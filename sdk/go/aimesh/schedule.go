@@ -0,0 +1,118 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schedule is a recurring message the broker enqueues on a cron schedule.
+type Schedule struct {
+	ID        string  `json:"schedule_id"`
+	CronExpr  string  `json:"cron"`
+	Template  Message `json:"template"`
+	NextRunAt int64   `json:"next_run_at"`
+	LastRunAt int64   `json:"last_run_at"`
+	CreatedAt int64   `json:"created_at"`
+}
+
+// CreateSchedule asks the broker to enqueue a copy of template every time
+// cronExpr fires. cronExpr uses the standard five-field syntax
+// ("0 2 * * *") or one of the @hourly, @daily, @weekly, @monthly and @yearly
+// shorthands. Each enqueued copy gets a fresh message ID and timestamp, and
+// a deadline as far after its timestamp as template's deadline is after
+// template's. The template is redacted like any sent message.
+func (c *Client) CreateSchedule(cronExpr string, template *Message, opts ...CallOption) (*Schedule, error) {
+	if err := validateCron(cronExpr); err != nil {
+		return nil, err
+	}
+	if template.OrgID == "" {
+		template.OrgID = c.callOptions(opts).orgID
+	}
+	if err := template.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.process(template); err != nil {
+		return nil, err
+	}
+
+	data, err := c.request("POST", "/schedules", map[string]interface{}{
+		"cron":     cronExpr,
+		"template": newScheduleTemplate(template),
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sched Schedule
+	if err := json.Unmarshal(data, &sched); err != nil {
+		return nil, err
+	}
+	sched.Template.decodePayload()
+
+	return &sched, nil
+}
+
+// scheduleTemplate sends a template's deadline relative to its timestamp,
+// since an absolute one would have passed by the time later copies are
+// enqueued.
+type scheduleTemplate struct {
+	*Message
+	DeadlineMs   int64 `json:"deadline_ms,omitempty"`
+	DeadlineInMs int64 `json:"deadline_in_ms,omitempty"`
+}
+
+func newScheduleTemplate(msg *Message) *scheduleTemplate {
+	return &scheduleTemplate{Message: msg, DeadlineInMs: deadlineIn(msg)}
+}
+
+// ListSchedules lists all recurring schedules.
+func (c *Client) ListSchedules(opts ...CallOption) ([]Schedule, error) {
+	data, err := c.request("GET", "/schedules", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Schedules []Schedule `json:"schedules"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Schedules {
+		resp.Schedules[i].Template.decodePayload()
+	}
+
+	return resp.Schedules, nil
+}
+
+// DeleteSchedule deletes a recurring schedule.
+func (c *Client) DeleteSchedule(scheduleID string, opts ...CallOption) error {
+	_, err := c.request("DELETE", "/schedules/"+scheduleID, nil, opts...)
+	return err
+}
+
+// validateCron performs a syntactic check of a cron expression so obvious
+// mistakes are reported before a round trip to the broker.
+func validateCron(expr string) error {
+	switch expr {
+	case "@hourly", "@daily", "@midnight", "@weekly", "@monthly", "@yearly", "@annually":
+		return nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return fmt.Errorf("%w: cron expression %q must have 5 fields, got %d", ErrValidation, expr, len(fields))
+	}
+	for _, f := range fields {
+		for _, r := range f {
+			switch {
+			case r >= '0' && r <= '9', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+			case r == '*', r == ',', r == '-', r == '/', r == '?':
+			default:
+				return fmt.Errorf("%w: invalid character %q in cron field %q", ErrValidation, r, f)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StatusPending matches messages that have not been acknowledged yet in
+// SearchMessages.
+const StatusPending = "pending"
+
+// MessageQuery selects messages for SearchMessages. Filters combine; zero
+// fields match everything.
+type MessageQuery struct {
+	AgentID string
+	// Metadata matches messages whose metadata contains all of these
+	// entries.
+	Metadata    map[string]string
+	TraceID     string
+	TaskGraphID string
+	// From and To bound the message timestamp.
+	From time.Time
+	To   time.Time
+	// Status is StatusPending, StatusSuccess, StatusFailed or
+	// StatusExpired.
+	Status string
+}
+
+// SearchResult is a message found by SearchMessages with its current
+// status.
+type SearchResult struct {
+	Message Message `json:"message"`
+	Status  string  `json:"status"`
+}
+
+// SearchPage is one page of search results.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// SearchMessages finds stored messages matching query, newest first. Pass
+// the NextCursor of a page as opts.Cursor to fetch the following page.
+func (c *Client) SearchMessages(query *MessageQuery, opts *ListOptions, callOpts ...CallOption) (*SearchPage, error) {
+	if query == nil {
+		query = &MessageQuery{}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, fmt.Errorf("%w: search start must be before its end", ErrValidation)
+	}
+	switch query.Status {
+	case "", StatusPending, StatusSuccess, StatusFailed, StatusExpired:
+	default:
+		return nil, fmt.Errorf("%w: unknown message status %q", ErrValidation, query.Status)
+	}
+
+	body := map[string]interface{}{}
+	if query.AgentID != "" {
+		body["agent_id"] = query.AgentID
+	}
+	if len(query.Metadata) > 0 {
+		body["metadata"] = query.Metadata
+	}
+	if query.TraceID != "" {
+		body["trace_id"] = query.TraceID
+	}
+	if query.TaskGraphID != "" {
+		body["task_graph_id"] = query.TaskGraphID
+	}
+	if !query.From.IsZero() {
+		body["from_ms"] = query.From.UnixMilli()
+	}
+	if !query.To.IsZero() {
+		body["to_ms"] = query.To.UnixMilli()
+	}
+	if query.Status != "" {
+		body["status"] = query.Status
+	}
+
+	data, err := c.request("POST", withQuery("/messages/search", opts.values()), body, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var page SearchPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	for i := range page.Results {
+		page.Results[i].Message.decodePayload()
+	}
+
+	return &page, nil
+}
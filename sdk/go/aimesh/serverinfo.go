@@ -0,0 +1,96 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned by calls needing a feature the broker does not
+// advertise.
+var ErrUnsupported = errors.New("not supported by broker")
+
+// Optional broker features.
+const (
+	FeatureBatching    = "batching"
+	FeatureStreaming   = "streaming"
+	FeatureCompression = "compression"
+)
+
+// ServerInfo describes the broker's build and what it supports.
+type ServerInfo struct {
+	Version         string   `json:"version"`
+	ProtocolVersion string   `json:"protocol_version"`
+	Features        []string `json:"features"`
+}
+
+// Supports reports whether the broker advertises feature.
+func (i *ServerInfo) Supports(feature string) bool {
+	for _, f := range i.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// serverInfoRetry is how long optional calls go ungated after the server
+// info could not be fetched before it is asked for again.
+const serverInfoRetry = 30 * time.Second
+
+// serverInfoCache holds the broker's advertised features for gating
+// optional calls.
+type serverInfoCache struct {
+	mu      sync.Mutex
+	info    *ServerInfo
+	checked bool
+	retryAt time.Time
+}
+
+// GetServerInfo gets the broker's version, protocol version and features.
+// The result also refreshes the features the client gates optional calls on.
+func (c *Client) GetServerInfo(opts ...CallOption) (*ServerInfo, error) {
+	return c.getServerInfo(context.Background(), opts...)
+}
+
+func (c *Client) getServerInfo(ctx context.Context, opts ...CallOption) (*ServerInfo, error) {
+	data, err := c.requestContext(ctx, "GET", "/info", nil, opts...)
+	if err != nil {
+		c.info.mu.Lock()
+		if errors.Is(err, ErrNotFound) {
+			c.info.checked = true
+		} else if ctx.Err() == nil {
+			c.info.retryAt = time.Now().Add(serverInfoRetry)
+		}
+		c.info.mu.Unlock()
+		return nil, err
+	}
+
+	var info ServerInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+
+	c.info.mu.Lock()
+	c.info.info, c.info.checked = &info, true
+	c.info.mu.Unlock()
+	return &info, nil
+}
+
+// supports reports whether the broker advertises feature, fetching the
+// server info on first use with the calling operation's ctx and options.
+// Brokers that predate GetServerInfo, or that cannot be asked right now, are
+// assumed to support everything so that the call itself decides; a failed
+// lookup is not retried for serverInfoRetry.
+func (c *Client) supports(ctx context.Context, feature string, opts ...CallOption) bool {
+	c.info.mu.Lock()
+	info, checked, retryAt := c.info.info, c.info.checked, c.info.retryAt
+	c.info.mu.Unlock()
+
+	if !checked && time.Now().After(retryAt) {
+		info, _ = c.getServerInfo(ctx, opts...)
+	}
+	return info == nil || info.Supports(feature)
+}
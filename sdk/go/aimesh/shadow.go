@@ -0,0 +1,47 @@
+package aimesh
+
+import (
+	"encoding/hex"
+	"encoding/json"
+)
+
+// WithShadowTo mirrors the message to candidate endpoints. The broker still
+// routes the message normally and returns the production acknowledgment;
+// the shadow copies' acknowledgments are recorded for evaluation with
+// GetShadowResults and never reach the caller. Shadow copies are not
+// charged to the agent's budget.
+func (m *Message) WithShadowTo(endpointIDs ...string) *Message {
+	m.ShadowTo = append(m.ShadowTo, endpointIDs...)
+	return m
+}
+
+// ShadowResult is the acknowledgment a shadow endpoint produced for a
+// mirrored message.
+type ShadowResult struct {
+	EndpointID string         `json:"endpoint_id"`
+	Ack        Acknowledgment `json:"ack"`
+}
+
+// GetShadowResults gets the recorded shadow acknowledgments of a message
+// sent with WithShadowTo.
+func (c *Client) GetShadowResults(messageID string, opts ...CallOption) ([]ShadowResult, error) {
+	data, err := c.request("GET", "/messages/"+messageID+"/shadow-results", nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Results []ShadowResult `json:"results"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Results {
+		ack := &resp.Results[i].Ack
+		if ack.ResultHex != "" {
+			ack.Result, _ = hex.DecodeString(ack.ResultHex)
+		}
+	}
+
+	return resp.Results, nil
+}
@@ -0,0 +1,103 @@
+package aimesh
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// openStream opens a server-sent event stream. Unlike regular calls it has
+// no timeout: the stream lives until ctx is done or the broker closes it.
+func (c *Client) openStream(ctx context.Context, method, path string, data []byte, o *callOptions, lastEventID string) (*http.Response, error) {
+	header := o.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		header.Set("Last-Event-ID", lastEventID)
+	}
+
+	auth, err := c.credentials(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	for _, base := range c.balancer.Pick() {
+		var resp *http.Response
+		resp, err = c.roundTrip(ctx, withQuery(base+path, o.query), method, data, header, auth)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		} else if resp.StatusCode >= 400 {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = newAPIError(resp.StatusCode, body)
+		}
+		c.balancer.Report(base, 0, err)
+		if err == nil {
+			return resp, nil
+		}
+		if !failsOver(err) || !resendable(method, err, o) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// sseEvent is one server-sent event.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// sseReader parses a server-sent event stream.
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &sseReader{scanner: scanner}
+}
+
+// next returns the next event, or io.EOF when the stream ends.
+func (r *sseReader) next() (*sseEvent, error) {
+	var ev sseEvent
+	var data []string
+	seen := false
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if seen {
+				ev.Data = strings.Join(data, "\n")
+				return &ev, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.ID = value
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+		default:
+			continue
+		}
+		seen = true
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
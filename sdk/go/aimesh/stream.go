@@ -0,0 +1,150 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ResultChunk is a partial result streamed while a message is processed,
+// such as a batch of tokens from an LLM endpoint.
+type ResultChunk struct {
+	Index   int    `json:"index"`
+	Data    []byte `json:"-"`
+	DataHex string `json:"data"`
+}
+
+// ResultStream iterates over the partial results of a message sent with
+// SendMessageStream, ending with its acknowledgment:
+//
+//	stream, err := client.SendMessageStream(ctx, msg)
+//	if err != nil {
+//		...
+//	}
+//	defer stream.Close()
+//	for stream.Next() {
+//		os.Stdout.Write(stream.Chunk().Data)
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+//	ack := stream.Ack()
+type ResultStream struct {
+	body   io.ReadCloser
+	events *sseReader
+
+	chunk     ResultChunk
+	pending   []ResultChunk
+	ack       *Acknowledgment
+	err       error
+	closeOnce sync.Once
+}
+
+// SendMessageStream sends a message and streams its result in chunks as the
+// endpoint produces them, followed by the final acknowledgment. Brokers that
+// do not advertise FeatureStreaming get a regular send, whose whole result
+// arrives as a single chunk. The stream is not retried once it has started.
+func (c *Client) SendMessageStream(ctx context.Context, msg *Message, opts ...CallOption) (*ResultStream, error) {
+	if !c.supports(ctx, FeatureStreaming) {
+		ack, err := c.SendMessageContext(ctx, msg, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s := &ResultStream{ack: ack}
+		if len(ack.Result) > 0 {
+			s.pending = []ResultChunk{{Data: ack.Result, DataHex: ack.ResultHex}}
+		}
+		return s, nil
+	}
+
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	defer c.end()
+
+	if err := c.prepare(ctx, msg, opts); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(c.toServerClock(msg))
+	if err != nil {
+		return nil, err
+	}
+	o := c.callOptions(append([]CallOption{idempotent(msg.DedupContext != "")}, opts...))
+	if o.err != nil {
+		return nil, o.err
+	}
+	resp, err := c.openStream(ctx, http.MethodPost, "/messages/stream", data, o, "")
+	if err != nil {
+		return nil, err
+	}
+	return &ResultStream{body: resp.Body, events: newSSEReader(resp.Body)}, nil
+}
+
+// Next advances to the next chunk. It returns false once the
+// acknowledgment has arrived or the stream failed.
+func (s *ResultStream) Next() bool {
+	if len(s.pending) > 0 {
+		s.chunk, s.pending = s.pending[0], s.pending[1:]
+		return true
+	}
+	if s.events == nil || s.ack != nil || s.err != nil {
+		return false
+	}
+	for {
+		ev, err := s.events.next()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			s.err = fmt.Errorf("%w: result stream ended before the acknowledgment: %v", ErrConnection, err)
+			s.Close()
+			return false
+		}
+		switch ev.Event {
+		case "chunk":
+			var chunk ResultChunk
+			if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+				s.err = err
+				s.Close()
+				return false
+			}
+			chunk.Data, _ = hex.DecodeString(chunk.DataHex)
+			s.chunk = chunk
+			return true
+		case "ack":
+			s.ack, s.err = decodeAck([]byte(ev.Data))
+			s.Close()
+			return false
+		}
+	}
+}
+
+// Chunk returns the current chunk.
+func (s *ResultStream) Chunk() *ResultChunk {
+	return &s.chunk
+}
+
+// Ack returns the message's acknowledgment once Next has returned false,
+// or nil if the stream failed.
+func (s *ResultStream) Ack() *Acknowledgment {
+	return s.ack
+}
+
+// Err returns the error that stopped the stream, if any.
+func (s *ResultStream) Err() error {
+	return s.err
+}
+
+// Close stops reading the stream. The message keeps being processed.
+func (s *ResultStream) Close() error {
+	s.closeOnce.Do(func() {
+		if s.body != nil {
+			s.body.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,593 @@
+package aimesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskGraph builds a DAG of messages that is submitted to the broker as one
+// unit. The broker dispatches a node once all the nodes it depends on have
+// succeeded.
+type TaskGraph struct {
+	ID string
+
+	// DefaultRetry applies to nodes without their own retry policy. Nil
+	// leaves retries to the broker default.
+	DefaultRetry *RetryPolicy
+
+	// CompensateOnFailure asks the broker to run the compensation messages
+	// of succeeded nodes, in reverse dependency order, when any node fails.
+	// Use Compensate to do the same from the SDK instead.
+	CompensateOnFailure bool
+
+	client *Client
+	nodes  map[string]*taskNode
+	order  []string
+	resume bool
+}
+
+// taskNode is a message in a task graph together with its node options.
+type taskNode struct {
+	msg          *Message
+	retry        *RetryPolicy
+	compensation *Message
+}
+
+// RetryPolicy tells the broker how to retry a failed task graph node.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry. Defaults to 2.
+	Multiplier float64
+	// FallbackAgents are tried in order, one per retry, once the original
+	// agent has used up its attempts.
+	FallbackAgents []string
+}
+
+// UnmarshalJSON decodes the policy from the broker's wire format.
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		MaxAttempts      int      `json:"max_attempts"`
+		InitialBackoffMs int64    `json:"initial_backoff_ms"`
+		MaxBackoffMs     int64    `json:"max_backoff_ms"`
+		Multiplier       float64  `json:"multiplier"`
+		FallbackAgents   []string `json:"fallback_agents"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*p = RetryPolicy{
+		MaxAttempts:    wire.MaxAttempts,
+		InitialBackoff: time.Duration(wire.InitialBackoffMs) * time.Millisecond,
+		MaxBackoff:     time.Duration(wire.MaxBackoffMs) * time.Millisecond,
+		Multiplier:     wire.Multiplier,
+		FallbackAgents: wire.FallbackAgents,
+	}
+	return nil
+}
+
+// Validate checks the policy for values the broker would reject.
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("%w: retry policy needs at least one attempt", ErrValidation)
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 || p.Multiplier < 0 {
+		return fmt.Errorf("%w: retry backoff must not be negative", ErrValidation)
+	}
+	if p.MaxBackoff > 0 && p.InitialBackoff > p.MaxBackoff {
+		return fmt.Errorf("%w: initial backoff %v exceeds max backoff %v", ErrValidation, p.InitialBackoff, p.MaxBackoff)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the policy in the broker's wire format.
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	return json.Marshal(map[string]interface{}{
+		"max_attempts":       p.MaxAttempts,
+		"initial_backoff_ms": p.InitialBackoff.Milliseconds(),
+		"max_backoff_ms":     p.MaxBackoff.Milliseconds(),
+		"multiplier":         multiplier,
+		"fallback_agents":    p.FallbackAgents,
+	})
+}
+
+// NewTaskGraph creates an empty task graph with a fresh ID.
+func (c *Client) NewTaskGraph() *TaskGraph {
+	return &TaskGraph{
+		ID:     uuid.New().String(),
+		client: c,
+		nodes:  make(map[string]*taskNode),
+	}
+}
+
+// AddNode adds msg to the graph and returns its node ID, which is the message
+// ID. A message ID is assigned if msg has none, and msg.TaskGraphID is set to
+// the graph ID.
+func (g *TaskGraph) AddNode(msg *Message) string {
+	if msg.MessageID == "" {
+		msg.MessageID = uuid.New().String()
+	}
+	msg.TaskGraphID = g.ID
+	if msg.Dependencies == nil {
+		msg.Dependencies = []string{}
+	}
+
+	if _, exists := g.nodes[msg.MessageID]; !exists {
+		g.order = append(g.order, msg.MessageID)
+	}
+	g.nodes[msg.MessageID] = &taskNode{msg: msg}
+	return msg.MessageID
+}
+
+// AddEdge makes node to depend on node from, so to is only dispatched after
+// from has succeeded.
+func (g *TaskGraph) AddEdge(from, to string) error {
+	if _, ok := g.nodes[from]; !ok {
+		return fmt.Errorf("%w: unknown task graph node %q", ErrValidation, from)
+	}
+	target, ok := g.nodes[to]
+	if !ok {
+		return fmt.Errorf("%w: unknown task graph node %q", ErrValidation, to)
+	}
+
+	for _, dep := range target.msg.Dependencies {
+		if dep == from {
+			return nil
+		}
+	}
+	target.msg.Dependencies = append(target.msg.Dependencies, from)
+	return nil
+}
+
+// Node returns the message for a node ID, or nil if there is no such node.
+func (g *TaskGraph) Node(id string) *Message {
+	if n, ok := g.nodes[id]; ok {
+		return n.msg
+	}
+	return nil
+}
+
+// SetRetryPolicy sets the retry policy of a node, overriding DefaultRetry.
+func (g *TaskGraph) SetRetryPolicy(nodeID string, policy *RetryPolicy) error {
+	n, ok := g.nodes[nodeID]
+	if !ok {
+		return fmt.Errorf("%w: unknown task graph node %q", ErrValidation, nodeID)
+	}
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	n.retry = policy
+	return nil
+}
+
+// SetCompensation registers the message that undoes the side effects of a
+// node. It is only sent if the node succeeded and the graph later fails.
+func (g *TaskGraph) SetCompensation(nodeID string, msg *Message) error {
+	n, ok := g.nodes[nodeID]
+	if !ok {
+		return fmt.Errorf("%w: unknown task graph node %q", ErrValidation, nodeID)
+	}
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	n.compensation = msg
+	return nil
+}
+
+// Compensate sends the compensation messages of every node that succeeded
+// according to status, in reverse dependency order, so later steps are
+// undone before the steps they built on. It stops at the first failure and
+// returns the node IDs compensated so far.
+func (g *TaskGraph) Compensate(status *TaskGraphStatus, opts ...CallOption) ([]string, error) {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var compensated []string
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		n := g.nodes[id]
+		if n.compensation == nil {
+			continue
+		}
+		if st := status.Node(id); st == nil || st.State != NodeSucceeded {
+			continue
+		}
+
+		msg := *n.compensation
+		msg.Metadata = make(map[string]string, len(n.compensation.Metadata)+1)
+		for k, v := range n.compensation.Metadata {
+			msg.Metadata[k] = v
+		}
+		msg.Metadata["compensates"] = id
+		if msg.TaskGraphID == "" {
+			msg.TaskGraphID = g.ID
+		}
+		if _, err := g.client.SendMessage(&msg, opts...); err != nil {
+			return compensated, fmt.Errorf("compensating node %s: %w", id, err)
+		}
+		compensated = append(compensated, id)
+	}
+	return compensated, nil
+}
+
+// Nodes returns the node IDs in the order they were added.
+func (g *TaskGraph) Nodes() []string {
+	return append([]string(nil), g.order...)
+}
+
+// Edge is a dependency between two task graph nodes: To depends on From.
+type Edge struct {
+	From string
+	To   string
+}
+
+// GraphError describes why a task graph is not a valid DAG. It unwraps to
+// ErrValidation.
+type GraphError struct {
+	// Dangling lists dependencies on nodes that are not in the graph.
+	Dangling []Edge
+	// Cycles lists dependency cycles as node paths, each ending where it
+	// started.
+	Cycles [][]string
+}
+
+func (e *GraphError) Error() string {
+	var parts []string
+	for _, edge := range e.Dangling {
+		parts = append(parts, fmt.Sprintf("node %s depends on unknown node %s", edge.To, edge.From))
+	}
+	for _, cycle := range e.Cycles {
+		parts = append(parts, "cycle "+strings.Join(cycle, " -> "))
+	}
+	return fmt.Sprintf("%v: invalid task graph: %s", ErrValidation, strings.Join(parts, "; "))
+}
+
+// Unwrap returns ErrValidation.
+func (e *GraphError) Unwrap() error {
+	return ErrValidation
+}
+
+// Validate checks that every dependency refers to a node in the graph and
+// that the dependencies form no cycles. It returns a *GraphError listing all
+// offending edges.
+func (g *TaskGraph) Validate() error {
+	_, err := g.TopologicalOrder()
+	return err
+}
+
+// TopologicalOrder returns the node IDs ordered so that every node comes
+// after the nodes it depends on. Nodes without an ordering constraint keep
+// the order they were added in.
+func (g *TaskGraph) TopologicalOrder() ([]string, error) {
+	gerr := &GraphError{}
+	indegree := make(map[string]int, len(g.nodes))
+	dependents := make(map[string][]string, len(g.nodes))
+	for _, id := range g.order {
+		for _, dep := range g.nodes[id].msg.Dependencies {
+			if _, ok := g.nodes[dep]; !ok {
+				gerr.Dangling = append(gerr.Dangling, Edge{From: dep, To: id})
+				continue
+			}
+			indegree[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	var ready, sorted []string
+	for _, id := range g.order {
+		if indegree[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, id)
+		for _, next := range dependents[id] {
+			if indegree[next]--; indegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	if len(sorted) < len(g.order) {
+		gerr.Cycles = g.findCycles()
+	}
+	if len(gerr.Dangling) > 0 || len(gerr.Cycles) > 0 {
+		return nil, gerr
+	}
+	return sorted, nil
+}
+
+// findCycles returns one node path for every back edge found by a
+// depth-first search over the dependency edges.
+func (g *TaskGraph) findCycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(g.nodes))
+	var stack []string
+	var cycles [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, dep := range g.nodes[id].msg.Dependencies {
+			if _, ok := g.nodes[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != dep {
+					start--
+				}
+				// The stack follows dependencies, so reverse it to read in
+				// execution order (From -> To).
+				cycle := []string{dep}
+				for i := len(stack) - 1; i >= start; i-- {
+					cycle = append(cycle, stack[i])
+				}
+				cycles = append(cycles, cycle)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+	}
+
+	for _, id := range g.order {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return cycles
+}
+
+// Submit validates the graph and posts it to the broker atomically: either
+// every node is accepted or none is. Nodes are sent in topological order.
+func (g *TaskGraph) Submit(opts ...CallOption) error {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return err
+	}
+	if err := g.client.begin(); err != nil {
+		return err
+	}
+	defer g.client.end()
+
+	if g.DefaultRetry != nil {
+		if err := g.DefaultRetry.Validate(); err != nil {
+			return err
+		}
+	}
+
+	orgID := g.client.callOptions(opts).orgID
+	nodes := make([]map[string]interface{}, 0, len(order))
+	for _, id := range order {
+		n := g.nodes[id]
+		n.msg.TaskGraphID = g.ID
+		if n.msg.OrgID == "" {
+			n.msg.OrgID = orgID
+		}
+		if err := n.msg.Validate(); err != nil {
+			return fmt.Errorf("node %s: %w", id, err)
+		}
+		if err := g.client.process(n.msg); err != nil {
+			return fmt.Errorf("node %s: %w", id, err)
+		}
+		node := map[string]interface{}{
+			"message": g.client.toServerClock(n.msg),
+		}
+		if retry := n.retry; retry != nil {
+			node["retry"] = retry
+		} else if g.DefaultRetry != nil {
+			node["retry"] = g.DefaultRetry
+		}
+		if comp := n.compensation; comp != nil {
+			if comp.OrgID == "" {
+				comp.OrgID = orgID
+			}
+			if err := comp.Validate(); err != nil {
+				return fmt.Errorf("node %s compensation: %w", id, err)
+			}
+			if err := g.client.process(comp); err != nil {
+				return fmt.Errorf("node %s compensation: %w", id, err)
+			}
+			node["compensation"] = g.client.toServerClock(comp)
+		}
+		nodes = append(nodes, node)
+	}
+
+	body := map[string]interface{}{
+		"task_graph_id": g.ID,
+		"nodes":         nodes,
+	}
+	if g.resume {
+		body["resume"] = true
+	}
+	if g.CompensateOnFailure {
+		body["compensate_on_failure"] = true
+	}
+	if _, err := g.client.request("POST", "/task-graphs", body, opts...); err != nil {
+		return err
+	}
+
+	if store := g.client.checkpoints; store != nil && !g.resume {
+		data, err := json.Marshal(g.snapshot())
+		if err != nil {
+			return err
+		}
+		return store.SaveGraph(g.ID, data)
+	}
+	return nil
+}
+
+// NodeState is the execution state of a task graph node.
+type NodeState string
+
+// Task graph node states.
+const (
+	NodePending   NodeState = "pending"
+	NodeBlocked   NodeState = "blocked"
+	NodeRunning   NodeState = "running"
+	NodeSucceeded NodeState = "succeeded"
+	NodeFailed    NodeState = "failed"
+	NodeCancelled NodeState = "cancelled"
+)
+
+// Finished reports whether the state is terminal.
+func (s NodeState) Finished() bool {
+	return s == NodeSucceeded || s == NodeFailed || s == NodeCancelled
+}
+
+// NodeStatus is the state of a single task graph node.
+type NodeStatus struct {
+	NodeID     string    `json:"node_id"`
+	AgentID    string    `json:"agent_id"`
+	State      NodeState `json:"state"`
+	TokensUsed float64   `json:"tokens_used"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"`
+	StartedAt  int64     `json:"started_at"`
+	FinishedAt int64     `json:"finished_at"`
+}
+
+// TaskGraphStatus is the execution state of a submitted task graph.
+type TaskGraphStatus struct {
+	TaskGraphID string       `json:"task_graph_id"`
+	Nodes       []NodeStatus `json:"nodes"`
+	TokensUsed  float64      `json:"tokens_used"`
+	SubmittedAt int64        `json:"submitted_at"`
+}
+
+// Node returns the status of a node, or nil if the graph has no such node.
+func (s *TaskGraphStatus) Node(id string) *NodeStatus {
+	for i := range s.Nodes {
+		if s.Nodes[i].NodeID == id {
+			return &s.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Counts returns the number of nodes in each state.
+func (s *TaskGraphStatus) Counts() map[NodeState]int {
+	counts := make(map[NodeState]int)
+	for _, n := range s.Nodes {
+		counts[n.State]++
+	}
+	return counts
+}
+
+// Progress returns the percentage of nodes that have finished.
+func (s *TaskGraphStatus) Progress() float64 {
+	if len(s.Nodes) == 0 {
+		return 0
+	}
+	finished := 0
+	for _, n := range s.Nodes {
+		if n.State.Finished() {
+			finished++
+		}
+	}
+	return float64(finished) / float64(len(s.Nodes)) * 100
+}
+
+// Done reports whether every node has finished.
+func (s *TaskGraphStatus) Done() bool {
+	for _, n := range s.Nodes {
+		if !n.State.Finished() {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed reports whether any node has failed.
+func (s *TaskGraphStatus) Failed() bool {
+	for _, n := range s.Nodes {
+		if n.State == NodeFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTaskGraph gets the per-node execution state of a task graph.
+func (c *Client) GetTaskGraph(taskGraphID string, opts ...CallOption) (*TaskGraphStatus, error) {
+	data, err := c.request("GET", "/task-graphs/"+taskGraphID, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var status TaskGraphStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// CancelTaskGraphOptions controls how far a task graph cancellation reaches.
+type CancelTaskGraphOptions struct {
+	// AbortRunning also aborts nodes that are already running. By default
+	// only pending and blocked nodes are cancelled and running nodes are
+	// allowed to finish.
+	AbortRunning bool
+	// Reason is recorded on every cancelled node.
+	Reason string
+}
+
+// CancelTaskGraphResult reports what a task graph cancellation affected.
+type CancelTaskGraphResult struct {
+	Cancelled int `json:"cancelled"`
+	Aborted   int `json:"aborted"`
+}
+
+// CancelTaskGraph cancels a task graph. Unlike CancelByTaskGraph, which only
+// withdraws queued messages, this marks the graph cancelled so that no
+// downstream node is dispatched afterwards, and optionally aborts running
+// nodes too.
+func (c *Client) CancelTaskGraph(taskGraphID string, opts *CancelTaskGraphOptions, callOpts ...CallOption) (*CancelTaskGraphResult, error) {
+	if opts == nil {
+		opts = &CancelTaskGraphOptions{}
+	}
+
+	body := map[string]interface{}{
+		"abort_running": opts.AbortRunning,
+	}
+	if opts.Reason != "" {
+		body["reason"] = opts.Reason
+	}
+
+	data, err := c.request("POST", "/task-graphs/"+taskGraphID+"/cancel", body, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result CancelTaskGraphResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
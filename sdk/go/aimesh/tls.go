@@ -0,0 +1,132 @@
+package aimesh
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig configures TLS connections to the broker.
+type TLSConfig struct {
+	// CertFile and KeyFile hold the client certificate presented to
+	// brokers that authenticate agents by certificate (mutual TLS). They
+	// are reloaded when they change on disk, so rotated certificates are
+	// picked up by new connections without a restart.
+	CertFile string
+	KeyFile  string
+	// ReloadInterval is how often the certificate files are checked for
+	// changes. Defaults to a minute.
+	ReloadInterval time.Duration
+
+	// RootCAs verifies the broker's certificate, for brokers using an
+	// internal CA. Defaults to the system roots. See LoadCertPool.
+	RootCAs *x509.CertPool
+	// MinVersion is the minimum TLS version, such as tls.VersionTLS13.
+	// Defaults to TLS 1.2.
+	MinVersion uint16
+	// ServerName overrides the name the broker's certificate is verified
+	// against, for brokers reached by IP or through a tunnel.
+	ServerName string
+	// InsecureSkipVerify accepts any broker certificate. Only use it in
+	// development.
+	InsecureSkipVerify bool
+}
+
+// LoadCertPool reads PEM-encoded CA certificates from files into a pool for
+// TLSConfig.RootCAs.
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%w: no certificates in %s", ErrValidation, file)
+		}
+	}
+	return pool, nil
+}
+
+// tlsClientConfig builds the transport's TLS configuration, or nil to keep
+// the defaults.
+func tlsClientConfig(config *TLSConfig) *tls.Config {
+	if config == nil {
+		return nil
+	}
+	t := &tls.Config{
+		RootCAs:            config.RootCAs,
+		MinVersion:         config.MinVersion,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		r := &certReloader{
+			certFile: config.CertFile,
+			keyFile:  config.KeyFile,
+			interval: config.ReloadInterval,
+		}
+		if r.interval <= 0 {
+			r.interval = time.Minute
+		}
+		t.GetClientCertificate = r.get
+	}
+	return t
+}
+
+// certReloader loads a client certificate on first use and again whenever
+// its files' modification times change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	checked time.Time
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func (r *certReloader) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil && time.Since(r.checked) < r.interval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return r.fallback(err)
+	}
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	return r.cert, nil
+}
+
+// fallback keeps presenting the last good certificate when a reload fails,
+// for example while the files are halfway through being replaced.
+func (r *certReloader) fallback(err error) (*tls.Certificate, error) {
+	if r.cert != nil {
+		return r.cert, nil
+	}
+	return nil, fmt.Errorf("client certificate: %w", err)
+}
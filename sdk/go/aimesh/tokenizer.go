@@ -0,0 +1,203 @@
+package aimesh
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tokenizer counts the tokens a model sees for a piece of text.
+type Tokenizer interface {
+	CountTokens(text []byte) int
+}
+
+// ApproxTokenizer estimates token counts from the text length. It is used for
+// models without a registered tokenizer.
+type ApproxTokenizer struct {
+	// BytesPerToken is the average token length. Defaults to 4.
+	BytesPerToken float64
+}
+
+// CountTokens implements Tokenizer.
+func (t ApproxTokenizer) CountTokens(text []byte) int {
+	per := t.BytesPerToken
+	if per <= 0 {
+		per = 4
+	}
+	return int(math.Ceil(float64(len(text)) / per))
+}
+
+var tokenizers = struct {
+	sync.RWMutex
+	byPrefix map[string]Tokenizer
+}{byPrefix: make(map[string]Tokenizer)}
+
+// RegisterTokenizer makes t the tokenizer for every model whose name starts
+// with prefix. When several prefixes match a model the longest one wins.
+func RegisterTokenizer(prefix string, t Tokenizer) {
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	tokenizers.byPrefix[prefix] = t
+}
+
+// TokenizerFor returns the tokenizer registered for model, or an
+// ApproxTokenizer if there is none.
+func TokenizerFor(model string) Tokenizer {
+	tokenizers.RLock()
+	defer tokenizers.RUnlock()
+
+	var best Tokenizer
+	bestLen := -1
+	for prefix, t := range tokenizers.byPrefix {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = t, len(prefix)
+		}
+	}
+	if best == nil {
+		return ApproxTokenizer{}
+	}
+	return best
+}
+
+// EstimateTokens counts the payload's tokens with the tokenizer for model,
+// stores the count in EstimatedCostToken and returns it.
+func (m *Message) EstimateTokens(model string) int {
+	n := TokenizerFor(model).CountTokens(m.Payload)
+	m.EstimatedCostToken = float64(n)
+	return n
+}
+
+// BPETokenizer is a byte-pair encoding tokenizer using tiktoken-style merge
+// ranks.
+type BPETokenizer struct {
+	ranks map[string]int
+	split *regexp.Regexp
+}
+
+// NewBPETokenizer creates a tokenizer from merge ranks and the pattern that
+// splits text into pieces before merging.
+func NewBPETokenizer(ranks map[string]int, pattern string) (*BPETokenizer, error) {
+	split, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: split pattern: %v", ErrValidation, err)
+	}
+	return &BPETokenizer{ranks: ranks, split: split}, nil
+}
+
+// LoadTiktoken reads a tiktoken rank file, one base64 token and its rank per
+// line, and creates a tokenizer from it.
+func LoadTiktoken(r io.Reader, pattern string) (*BPETokenizer, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: tiktoken line %d: want token and rank", ErrValidation, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: tiktoken line %d: %v", ErrValidation, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: tiktoken line %d: %v", ErrValidation, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewBPETokenizer(ranks, pattern)
+}
+
+// CountTokens implements Tokenizer.
+func (t *BPETokenizer) CountTokens(text []byte) int {
+	n := 0
+	for _, piece := range t.split.FindAll(text, -1) {
+		n += t.countPiece(piece)
+	}
+	return n
+}
+
+// countPiece merges the bytes of piece, lowest rank first, until no adjacent
+// pair is a known token, and returns the number of tokens left.
+func (t *BPETokenizer) countPiece(piece []byte) int {
+	if _, ok := t.ranks[string(piece)]; ok {
+		return 1
+	}
+
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			rank, ok := t.ranks[string(piece[bounds[i]:bounds[i+2]])]
+			if ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// BPEFamily describes the encoding shared by a family of models. Go's regexp
+// has no look-ahead, so the split patterns differ from the reference ones at
+// runs of trailing whitespace; counts can be off by a token there.
+type BPEFamily struct {
+	Name    string
+	Pattern string
+	// Models are the model name prefixes that use this encoding.
+	Models []string
+}
+
+// Common encoding families. Their rank files are published alongside the
+// models and are not bundled with the SDK.
+var (
+	BPEFamilyR50K = BPEFamily{
+		Name:    "r50k_base",
+		Pattern: `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+`,
+		Models:  []string{"gpt2", "davinci", "curie", "babbage", "ada"},
+	}
+	BPEFamilyCL100K = BPEFamily{
+		Name:    "cl100k_base",
+		Pattern: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`,
+		Models:  []string{"gpt-4", "gpt-3.5", "text-embedding-3", "text-embedding-ada"},
+	}
+	BPEFamilyO200K = BPEFamily{
+		Name: "o200k_base",
+		Pattern: `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+			`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+			`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+`,
+		Models: []string{"gpt-4o", "gpt-4.1", "o1", "o3", "o4"},
+	}
+)
+
+// Load reads the family's tiktoken rank file and registers the resulting
+// tokenizer for the family's models.
+func (f BPEFamily) Load(r io.Reader) (*BPETokenizer, error) {
+	t, err := LoadTiktoken(r, f.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	for _, model := range f.Models {
+		RegisterTokenizer(model, t)
+	}
+	return t, nil
+}
@@ -0,0 +1,532 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Topic names are hierarchical, with segments separated by dots
+// ("org.team.event"). Subscription patterns may use "*" to match exactly one
+// segment and a trailing ">" to match one or more remaining segments, so
+// "org.*.completed" matches "org.search.completed" and "org.>" matches every
+// topic under "org".
+const (
+	topicSeparator   = "."
+	wildcardSegment  = "*"
+	wildcardTrailing = ">"
+)
+
+// ValidateTopic checks that name is a concrete topic name: non-empty
+// dot-separated segments made of letters, digits, '-' and '_'.
+func ValidateTopic(name string) error {
+	for _, seg := range strings.Split(name, topicSeparator) {
+		if !validTopicSegment(seg) {
+			return fmt.Errorf("%w: invalid topic %q", ErrValidation, name)
+		}
+	}
+	return nil
+}
+
+// ValidateTopicPattern checks that pattern is a topic name optionally using
+// the "*" and trailing ">" wildcards.
+func ValidateTopicPattern(pattern string) error {
+	segs := strings.Split(pattern, topicSeparator)
+	for i, seg := range segs {
+		switch {
+		case seg == wildcardSegment:
+		case seg == wildcardTrailing && i == len(segs)-1:
+		case validTopicSegment(seg):
+		default:
+			return fmt.Errorf("%w: invalid topic pattern %q", ErrValidation, pattern)
+		}
+	}
+	return nil
+}
+
+func validTopicSegment(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	for _, r := range seg {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// TopicMatches reports whether topic matches pattern.
+func TopicMatches(pattern, topic string) bool {
+	ps := strings.Split(pattern, topicSeparator)
+	ts := strings.Split(topic, topicSeparator)
+	for i, p := range ps {
+		if p == wildcardTrailing {
+			return len(ts) > i
+		}
+		if i >= len(ts) || (p != wildcardSegment && p != ts[i]) {
+			return false
+		}
+	}
+	return len(ps) == len(ts)
+}
+
+func isTopicPattern(pattern string) bool {
+	for _, seg := range strings.Split(pattern, topicSeparator) {
+		if seg == wildcardSegment || seg == wildcardTrailing {
+			return true
+		}
+	}
+	return false
+}
+
+// ListTopics lists the topics known to the broker.
+func (c *Client) ListTopics(opts ...CallOption) ([]string, error) {
+	data, err := c.request("GET", "/topics", nil, append([]CallOption{conditional()}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Topics []string `json:"topics"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Topics, nil
+}
+
+// PublishResult reports the outcome of a Publish call.
+type PublishResult struct {
+	MessageID   string `json:"message_id"`
+	Subscribers int    `json:"subscribers"`
+}
+
+// Publish sends msg to every subscriber of topic. msg.AgentID is ignored by
+// the broker for topic messages.
+func (c *Client) Publish(topic string, msg *Message, opts ...CallOption) (*PublishResult, error) {
+	if err := ValidateTopic(topic); err != nil {
+		return nil, err
+	}
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	defer c.end()
+
+	msg.Topic = topic
+	if msg.OrgID == "" {
+		msg.OrgID = c.callOptions(opts).orgID
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.process(msg); err != nil {
+		return nil, err
+	}
+	data, err := c.request("POST", "/topics/"+topic+"/messages", c.toServerClock(msg), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result PublishResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SubscribeOptions configures a topic subscription.
+type SubscribeOptions struct {
+	// Name identifies a durable subscription. Reconnecting with the same
+	// name resumes where the previous subscriber stopped.
+	Name string
+	// Durable keeps the subscription and its backlog on the broker when the
+	// subscriber disconnects or closes. Durable subscriptions need a Name.
+	Durable bool
+	// BufferSize is the capacity of the delivery channel. Defaults to 16.
+	BufferSize int
+	// PollWait is the long-poll duration of each fetch. Defaults to 10s.
+	PollWait time.Duration
+	// Prefetch limits how many delivered messages may be unacknowledged at
+	// once. When the window is full no more messages are fetched until Ack
+	// is called, so delivery adapts to processing speed. Zero disables flow
+	// control.
+	Prefetch int
+	// Filter restricts delivery to matching messages. It is evaluated by the
+	// broker; see ValidateFilter for the syntax.
+	Filter string
+	// RefreshInterval is how often a wildcard subscription looks for new
+	// matching topics. Defaults to 30s.
+	RefreshInterval time.Duration
+}
+
+// Subscription delivers topic messages on C until it is closed. Delivered
+// messages should be acknowledged with Ack.
+//
+// A subscription to a wildcard pattern is resolved by the SDK: it subscribes
+// to every matching topic the broker knows, periodically picks up new ones,
+// and merges their messages onto C. Message.Topic tells them apart.
+type Subscription struct {
+	// C receives subscription messages. It is closed when the subscription
+	// stops; Err then reports why.
+	C <-chan *Message
+
+	client  *Client
+	pattern string
+	opts    SubscribeOptions
+	out     chan *Message
+
+	window *credits
+
+	mu    sync.Mutex
+	feeds map[string]*topicFeed
+	err   error
+
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// topicFeed is the broker-side subscription to a single concrete topic.
+type topicFeed struct {
+	topic string
+
+	mu sync.Mutex
+	id string
+}
+
+func (f *topicFeed) subscriptionID() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.id
+}
+
+// SubscribeTopic subscribes to a topic or topic pattern. Delivery runs in the
+// background until Close is called. Transient connection failures are
+// retried; a durable subscription is re-created by name if the broker lost
+// it.
+func (c *Client) SubscribeTopic(topic string, opts *SubscribeOptions) (*Subscription, error) {
+	if err := ValidateTopicPattern(topic); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &SubscribeOptions{}
+	}
+	if opts.Durable && opts.Name == "" {
+		return nil, fmt.Errorf("%w: durable subscriptions need a name", ErrValidation)
+	}
+	if opts.Filter != "" {
+		if err := ValidateFilter(opts.Filter); err != nil {
+			return nil, err
+		}
+	}
+	o := *opts
+	if o.BufferSize <= 0 {
+		o.BufferSize = 16
+	}
+	if o.PollWait <= 0 {
+		o.PollWait = 10 * time.Second
+	}
+	if o.RefreshInterval <= 0 {
+		o.RefreshInterval = 30 * time.Second
+	}
+
+	s := &Subscription{
+		client:  c,
+		pattern: topic,
+		opts:    o,
+		out:     make(chan *Message, o.BufferSize),
+		feeds:   make(map[string]*topicFeed),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.C = s.out
+	if o.Prefetch > 0 {
+		s.window = newCredits(o.Prefetch)
+	}
+
+	if isTopicPattern(topic) {
+		if err := s.refresh(); err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.wg.Add(1)
+		go s.discover()
+	} else if err := s.addFeed(topic); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		s.wg.Wait()
+		close(s.out)
+	}()
+	if err := c.track(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// addFeed subscribes to a concrete topic and starts delivering from it.
+func (s *Subscription) addFeed(topic string) error {
+	f := &topicFeed{topic: topic}
+	if err := s.create(f); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.feeds[topic] = f
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run(f)
+	return nil
+}
+
+// create registers the feed's subscription with the broker.
+func (s *Subscription) create(f *topicFeed) error {
+	name := s.opts.Name
+	if name != "" && isTopicPattern(s.pattern) {
+		name += "/" + f.topic
+	}
+	body := map[string]interface{}{
+		"topic":   f.topic,
+		"name":    name,
+		"durable": s.opts.Durable,
+	}
+	if s.opts.Filter != "" {
+		body["filter"] = s.opts.Filter
+	}
+	if s.opts.Prefetch > 0 {
+		body["prefetch"] = s.opts.Prefetch
+	}
+	data, err := s.client.request("POST", "/subscriptions", body)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		SubscriptionID string `json:"subscription_id"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.id = resp.SubscriptionID
+	f.mu.Unlock()
+	return nil
+}
+
+// discover periodically subscribes to newly created topics matching a
+// wildcard pattern.
+func (s *Subscription) discover() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.refresh()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// refresh subscribes to matching topics that have no feed yet.
+func (s *Subscription) refresh() error {
+	topics, err := s.client.ListTopics()
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range topics {
+		if !TopicMatches(s.pattern, topic) {
+			continue
+		}
+		s.mu.Lock()
+		_, exists := s.feeds[topic]
+		s.mu.Unlock()
+		if exists {
+			continue
+		}
+		if err := s.addFeed(topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run fetches messages for a feed and delivers them on C until the
+// subscription stops.
+func (s *Subscription) run(f *topicFeed) {
+	defer s.wg.Done()
+
+	backoff := time.Second
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		default:
+		}
+
+		max := s.opts.BufferSize
+		if s.window != nil {
+			if max = s.window.acquire(max, s.ctx.Done()); max == 0 {
+				return
+			}
+		}
+
+		msgs, err := s.fetch(f, max)
+		if s.window != nil {
+			s.window.release(max - len(msgs))
+		}
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				if !s.opts.Durable {
+					s.mu.Lock()
+					delete(s.feeds, f.topic)
+					s.err = err
+					s.mu.Unlock()
+					return
+				}
+				err = s.create(f)
+			}
+			if err != nil {
+				select {
+				case <-time.After(backoff):
+				case <-s.ctx.Done():
+					return
+				}
+				if backoff < 30*time.Second {
+					backoff *= 2
+				}
+				continue
+			}
+		}
+		backoff = time.Second
+
+		for i := range msgs {
+			if msgs[i].Topic == "" {
+				msgs[i].Topic = f.topic
+			}
+			select {
+			case s.out <- &msgs[i]:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (s *Subscription) fetch(f *topicFeed, max int) ([]Message, error) {
+	v := url.Values{
+		"max":     {strconv.Itoa(max)},
+		"wait_ms": {strconv.FormatInt(s.opts.PollWait.Milliseconds(), 10)},
+	}
+	data, err := s.client.requestContext(s.ctx, "GET", withQuery("/subscriptions/"+f.subscriptionID()+"/messages", v), nil, longPoll(s.opts.PollWait))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Messages []Message `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Messages {
+		resp.Messages[i].decodePayload()
+	}
+
+	return resp.Messages, nil
+}
+
+// Ack acknowledges delivered messages so the broker does not redeliver them.
+func (s *Subscription) Ack(msgs ...*Message) error {
+	byFeed := make(map[*topicFeed][]string)
+	s.mu.Lock()
+	for _, m := range msgs {
+		f, ok := s.feeds[m.Topic]
+		if !ok {
+			s.mu.Unlock()
+			return fmt.Errorf("%w: message %s is not from this subscription", ErrValidation, m.MessageID)
+		}
+		byFeed[f] = append(byFeed[f], m.MessageID)
+	}
+	s.mu.Unlock()
+
+	for f, ids := range byFeed {
+		_, err := s.client.request("POST", "/subscriptions/"+f.subscriptionID()+"/ack", map[string]interface{}{
+			"message_ids": ids,
+		})
+		if err != nil {
+			return err
+		}
+		if s.window != nil {
+			s.window.release(len(ids))
+		}
+	}
+	return nil
+}
+
+// Err returns the error that stopped the subscription, if any.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops delivery. Non-durable subscriptions are deleted from the
+// broker; durable ones keep accumulating messages until resubscribed.
+func (s *Subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.client.untrack(s)
+		s.cancel()
+		s.wg.Wait()
+		if s.opts.Durable {
+			return
+		}
+
+		s.mu.Lock()
+		feeds := make([]*topicFeed, 0, len(s.feeds))
+		for _, f := range s.feeds {
+			feeds = append(feeds, f)
+		}
+		s.mu.Unlock()
+
+		for _, f := range feeds {
+			_, delErr := s.client.request("DELETE", "/subscriptions/"+f.subscriptionID(), nil)
+			if delErr != nil && !errors.Is(delErr, ErrNotFound) && err == nil {
+				err = delErr
+			}
+		}
+	})
+	return err
+}
+
+func (s *Subscription) drain(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
@@ -0,0 +1,372 @@
+package aimesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReceiveOptions controls a Receive call.
+type ReceiveOptions struct {
+	// MaxMessages caps how many messages are returned. Defaults to 1.
+	MaxMessages int
+	// WaitTime long-polls for up to this long when the queue is empty. It
+	// must be shorter than the client Timeout.
+	WaitTime time.Duration
+	// Filter is evaluated by the broker; only matching messages are
+	// delivered. See ValidateFilter for the syntax.
+	Filter string
+}
+
+// Receive pulls pending messages for an agent. Each returned message must be
+// settled with Ack or Nack; DeliveryCount reports how many times it has been
+// handed out, including this delivery.
+func (c *Client) Receive(agentID string, opts *ReceiveOptions, callOpts ...CallOption) ([]Message, error) {
+	return c.receive(context.Background(), "/agents/"+agentID+"/messages", opts, callOpts...)
+}
+
+// receive pulls messages from a delivery path with the given options.
+func (c *Client) receive(ctx context.Context, path string, opts *ReceiveOptions, callOpts ...CallOption) ([]Message, error) {
+	if opts == nil {
+		opts = &ReceiveOptions{}
+	}
+	max := opts.MaxMessages
+	if max <= 0 {
+		max = 1
+	}
+	v := url.Values{"max": {strconv.Itoa(max)}}
+	if opts.WaitTime > 0 {
+		v.Set("wait_ms", strconv.FormatInt(opts.WaitTime.Milliseconds(), 10))
+	}
+	if opts.Filter != "" {
+		if err := ValidateFilter(opts.Filter); err != nil {
+			return nil, err
+		}
+		v.Set("filter", opts.Filter)
+	}
+
+	callOpts = append([]CallOption{longPoll(opts.WaitTime)}, callOpts...)
+	data, err := c.requestContext(ctx, "GET", withQuery(path, v), nil, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Messages []Message `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	for i := range resp.Messages {
+		resp.Messages[i].decodePayload()
+	}
+
+	return resp.Messages, nil
+}
+
+// Handler processes a received message. Returning a nil error acknowledges
+// the message; the returned Acknowledgment may be nil, in which case a plain
+// success acknowledgment is sent. Returning an error nacks the message.
+type Handler func(ctx context.Context, msg *Message) (*Acknowledgment, error)
+
+// PoisonPolicy dead-letters messages that keep failing so that a crashing
+// handler does not spin on the same message forever.
+type PoisonPolicy struct {
+	// MaxDeliveries is the number of failed deliveries after which the
+	// message is dead-lettered with the last handler error.
+	MaxDeliveries int
+}
+
+// WorkerConfig configures a Worker.
+type WorkerConfig struct {
+	AgentID string
+	Handler Handler
+	// Concurrency is the number of messages handled in parallel. Defaults to 1.
+	Concurrency int
+	// PollWait is the long-poll duration of each Receive. Defaults to 10s.
+	PollWait time.Duration
+	// Prefetch is the flow-control window: the maximum number of received
+	// messages that may be waiting or in progress at once. Receiving stops
+	// while the window is full and resumes as the handler settles messages.
+	// Defaults to Concurrency.
+	Prefetch int
+	// Filter restricts delivery to matching messages. See ValidateFilter.
+	Filter string
+	// ConsumerGroup makes the worker a member of the named consumer group,
+	// so that replicas of the same agent share its queue and each message
+	// is processed by exactly one of them.
+	ConsumerGroup string
+	// Group configures the consumer group membership.
+	Group *ConsumerGroupOptions
+	// RetryDelay postpones redelivery of messages whose handler failed.
+	RetryDelay time.Duration
+	// Poison dead-letters repeatedly failing messages. Nil disables it.
+	Poison *PoisonPolicy
+	// OnError is called for receive, ack and handler errors.
+	OnError func(msg *Message, err error)
+}
+
+// Worker receives messages for an agent and dispatches them to a Handler.
+// Messages sharing an OrderingKey are handled sequentially in delivery
+// order, even when Concurrency is greater than one.
+type Worker struct {
+	client *Client
+	config WorkerConfig
+
+	mu      sync.Mutex
+	ordered map[string][]*Message
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewWorker creates a Worker. Call Run to start processing.
+func (c *Client) NewWorker(config WorkerConfig) *Worker {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.PollWait <= 0 {
+		config.PollWait = 10 * time.Second
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = config.Concurrency
+	}
+	return &Worker{client: c, config: config, ordered: make(map[string][]*Message)}
+}
+
+// Run processes messages until ctx is cancelled or the client is closed,
+// then waits for in-flight handlers to finish and returns the context error.
+func (w *Worker) Run(ctx context.Context) error {
+	if w.config.Handler == nil {
+		return fmt.Errorf("%w: worker has no handler", ErrValidation)
+	}
+	if w.config.Filter != "" {
+		if err := ValidateFilter(w.config.Filter); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopped := make(chan struct{})
+	w.mu.Lock()
+	w.stop, w.stopped = cancel, stopped
+	w.mu.Unlock()
+	if err := w.client.track(w); err != nil {
+		return err
+	}
+	defer func() {
+		w.client.untrack(w)
+		close(stopped)
+	}()
+
+	receive := func(opts *ReceiveOptions) ([]Message, error) {
+		return w.client.receive(ctx, "/agents/"+w.config.AgentID+"/messages", opts)
+	}
+	var group *ConsumerGroup
+	if w.config.ConsumerGroup != "" {
+		var err error
+		group, err = w.client.JoinConsumerGroup(w.config.ConsumerGroup, w.config.AgentID, w.config.Group)
+		if err != nil {
+			return err
+		}
+		// The worker leaves the group itself once its handlers are done.
+		w.client.untrack(group)
+		defer group.Leave()
+		receive = func(opts *ReceiveOptions) ([]Message, error) {
+			return group.receive(ctx, opts)
+		}
+	}
+
+	sem := make(chan struct{}, w.config.Concurrency)
+	window := newCredits(w.config.Prefetch)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for ctx.Err() == nil {
+		max := window.acquire(w.config.Prefetch, ctx.Done())
+		if max == 0 {
+			break
+		}
+		msgs, err := receive(&ReceiveOptions{
+			MaxMessages: max,
+			WaitTime:    w.config.PollWait,
+			Filter:      w.config.Filter,
+		})
+		window.release(max - len(msgs))
+		if err != nil {
+			w.reportError(nil, err)
+			sleepContext(ctx, time.Second)
+			continue
+		}
+
+		for i := range msgs {
+			msg := &msgs[i]
+			if msg.OrderingKey != "" && w.enqueueOrdered(msg) {
+				continue
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				w.releaseOrdered(msg.OrderingKey)
+				return ctx.Err()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				w.handle(ctx, msg)
+				if group != nil {
+					group.Done(msg)
+				}
+				window.release(1)
+				if msg.OrderingKey != "" {
+					w.drainOrdered(ctx, msg.OrderingKey, group, window)
+				}
+			}()
+		}
+	}
+
+	return ctx.Err()
+}
+
+// drain stops a running worker and waits for its in-flight handlers.
+func (w *Worker) drain(ctx context.Context) error {
+	w.mu.Lock()
+	stop, stopped := w.stop, w.stopped
+	w.mu.Unlock()
+
+	stop()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// enqueueOrdered queues msg behind an in-progress message with the same
+// ordering key. It returns false if no such message exists, in which case the
+// key is marked in progress and the caller must handle msg itself.
+func (w *Worker) enqueueOrdered(msg *Message) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	queue, active := w.ordered[msg.OrderingKey]
+	if !active {
+		w.ordered[msg.OrderingKey] = nil
+		return false
+	}
+	w.ordered[msg.OrderingKey] = append(queue, msg)
+	return true
+}
+
+// releaseOrdered forgets an ordering key claimed by enqueueOrdered whose
+// message was never handled.
+func (w *Worker) releaseOrdered(key string) {
+	if key == "" {
+		return
+	}
+	w.mu.Lock()
+	delete(w.ordered, key)
+	w.mu.Unlock()
+}
+
+// drainOrdered handles messages queued behind key until none are left.
+func (w *Worker) drainOrdered(ctx context.Context, key string, group *ConsumerGroup, window *credits) {
+	for {
+		w.mu.Lock()
+		queue := w.ordered[key]
+		if len(queue) == 0 {
+			delete(w.ordered, key)
+			w.mu.Unlock()
+			return
+		}
+		msg := queue[0]
+		w.ordered[key] = queue[1:]
+		w.mu.Unlock()
+
+		w.handle(ctx, msg)
+		if group != nil {
+			group.Done(msg)
+		}
+		window.release(1)
+	}
+}
+
+// handle runs the handler for one message and settles it.
+func (w *Worker) handle(ctx context.Context, msg *Message) {
+	if msg.IsExpired() {
+		ack := &Acknowledgment{OriginalMessageID: msg.MessageID, Status: StatusExpired}
+		if err := w.client.Ack(ack); err != nil {
+			w.reportError(msg, err)
+		}
+		return
+	}
+
+	start := time.Now()
+	ack, err := w.invoke(ctx, msg)
+	if err == nil {
+		if ack == nil {
+			ack = &Acknowledgment{}
+		}
+		ack.OriginalMessageID = msg.MessageID
+		if ack.Status == "" {
+			ack.Status = StatusSuccess
+		}
+		if ack.ProcessingLatencyMs == 0 {
+			ack.ProcessingLatencyMs = int(time.Since(start).Milliseconds())
+		}
+		if err := w.client.Ack(ack); err != nil {
+			w.reportError(msg, err)
+		}
+		return
+	}
+
+	w.reportError(msg, err)
+	nack := &NackOptions{
+		Requeue: true,
+		Delay:   w.config.RetryDelay,
+		Reason:  &FailureReason{Code: "handler_error", Message: err.Error()},
+	}
+	if p := w.config.Poison; p != nil && p.MaxDeliveries > 0 && msg.DeliveryCount >= p.MaxDeliveries {
+		nack = &NackOptions{
+			Reason: &FailureReason{
+				Code:    "poison_message",
+				Message: err.Error(),
+				Details: map[string]string{"delivery_count": strconv.Itoa(msg.DeliveryCount)},
+			},
+		}
+	}
+	if err := w.client.Nack(msg.MessageID, nack); err != nil {
+		w.reportError(msg, err)
+	}
+}
+
+// invoke calls the handler, converting a panic into an error.
+func (w *Worker) invoke(ctx context.Context, msg *Message) (ack *Acknowledgment, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return w.config.Handler(ctx, msg)
+}
+
+func (w *Worker) reportError(msg *Message, err error) {
+	if w.config.OnError != nil {
+		w.config.OnError(msg, err)
+	}
+}
+
+// sleepContext sleeps for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,207 @@
+package aimesh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowNodeKey is the metadata key holding the spec ID of a node built by
+// LoadWorkflow.
+const WorkflowNodeKey = "workflow_node"
+
+// WorkflowSpec is a declarative pipeline definition, written in YAML or JSON:
+//
+//	name: nightly-summary
+//	vars:
+//	  date: "2024-01-01"
+//	budget_tokens: 20000
+//	defaults:
+//	  budget_tokens: 2000
+//	  retry: {max_attempts: 3, initial_backoff: 1s}
+//	nodes:
+//	  - id: fetch
+//	    agent: fetcher
+//	    payload: "fetch reports for {{.date}}"
+//	  - id: summarize
+//	    agent: summarizer
+//	    payload: "summarize {{.date}}"
+//	    depends_on: [fetch]
+//
+// Payloads and metadata values are text/template strings expanded with the
+// spec vars, overridden by the vars passed to LoadWorkflow.
+type WorkflowSpec struct {
+	Name         string            `yaml:"name"`
+	Vars         map[string]string `yaml:"vars"`
+	BudgetTokens float64           `yaml:"budget_tokens"`
+	Defaults     WorkflowDefaults  `yaml:"defaults"`
+	Nodes        []WorkflowNode    `yaml:"nodes"`
+}
+
+// WorkflowDefaults holds values applied to nodes that do not set their own.
+type WorkflowDefaults struct {
+	Priority     int                `yaml:"priority"`
+	BudgetTokens float64            `yaml:"budget_tokens"`
+	Deadline     time.Duration      `yaml:"deadline"`
+	Retry        *WorkflowRetrySpec `yaml:"retry"`
+}
+
+// WorkflowNode is one node of a workflow spec.
+type WorkflowNode struct {
+	ID           string             `yaml:"id"`
+	Agent        string             `yaml:"agent"`
+	Payload      string             `yaml:"payload"`
+	Priority     int                `yaml:"priority"`
+	BudgetTokens float64            `yaml:"budget_tokens"`
+	Deadline     time.Duration      `yaml:"deadline"`
+	DependsOn    []string           `yaml:"depends_on"`
+	Metadata     map[string]string  `yaml:"metadata"`
+	Retry        *WorkflowRetrySpec `yaml:"retry"`
+}
+
+// WorkflowRetrySpec is the spec form of a RetryPolicy.
+type WorkflowRetrySpec struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	FallbackAgents []string      `yaml:"fallback_agents"`
+}
+
+func (r *WorkflowRetrySpec) policy() *RetryPolicy {
+	if r == nil {
+		return nil
+	}
+	return &RetryPolicy{
+		MaxAttempts:    r.MaxAttempts,
+		InitialBackoff: r.InitialBackoff,
+		MaxBackoff:     r.MaxBackoff,
+		Multiplier:     r.Multiplier,
+		FallbackAgents: r.FallbackAgents,
+	}
+}
+
+// LoadWorkflow reads a workflow spec from r, expands its templates and
+// builds the corresponding TaskGraph, ready to Submit. vars override the
+// defaults declared in the spec. The returned graph has already passed
+// Validate.
+func (c *Client) LoadWorkflow(r io.Reader, vars map[string]string) (*TaskGraph, error) {
+	var spec WorkflowSpec
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%w: workflow spec: %v", ErrValidation, err)
+	}
+	return c.BuildWorkflow(&spec, vars)
+}
+
+// BuildWorkflow builds a TaskGraph from an already parsed workflow spec. See
+// LoadWorkflow.
+func (c *Client) BuildWorkflow(spec *WorkflowSpec, vars map[string]string) (*TaskGraph, error) {
+	if len(spec.Nodes) == 0 {
+		return nil, fmt.Errorf("%w: workflow %q has no nodes", ErrValidation, spec.Name)
+	}
+
+	data := make(map[string]string, len(spec.Vars)+len(vars))
+	for k, v := range spec.Vars {
+		data[k] = v
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+
+	g := c.NewTaskGraph()
+	g.DefaultRetry = spec.Defaults.Retry.policy()
+	ids := make(map[string]string, len(spec.Nodes))
+	var total float64
+
+	for _, node := range spec.Nodes {
+		if node.ID == "" || node.Agent == "" {
+			return nil, fmt.Errorf("%w: workflow nodes need an id and an agent", ErrValidation)
+		}
+		if _, dup := ids[node.ID]; dup {
+			return nil, fmt.Errorf("%w: duplicate workflow node %q", ErrValidation, node.ID)
+		}
+
+		payload, err := expandTemplate(node.ID+".payload", node.Payload, data)
+		if err != nil {
+			return nil, err
+		}
+		msg := NewMessage(node.Agent, []byte(payload))
+		msg.Metadata[WorkflowNodeKey] = node.ID
+		if spec.Name != "" {
+			msg.Metadata["workflow"] = spec.Name
+		}
+		for k, v := range node.Metadata {
+			if msg.Metadata[k], err = expandTemplate(node.ID+".metadata."+k, v, data); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case node.Priority != 0:
+			msg.Priority = node.Priority
+		case spec.Defaults.Priority != 0:
+			msg.Priority = spec.Defaults.Priority
+		}
+		switch {
+		case node.BudgetTokens > 0:
+			msg.BudgetTokens = node.BudgetTokens
+		case spec.Defaults.BudgetTokens > 0:
+			msg.BudgetTokens = spec.Defaults.BudgetTokens
+		}
+		switch {
+		case node.Deadline > 0:
+			msg.DeadlineMs = time.Now().Add(node.Deadline).UnixMilli()
+		case spec.Defaults.Deadline > 0:
+			msg.DeadlineMs = time.Now().Add(spec.Defaults.Deadline).UnixMilli()
+		}
+		total += msg.BudgetTokens
+
+		ids[node.ID] = g.AddNode(msg)
+		if retry := node.Retry.policy(); retry != nil {
+			if err := g.SetRetryPolicy(ids[node.ID], retry); err != nil {
+				return nil, fmt.Errorf("node %s: %w", node.ID, err)
+			}
+		}
+	}
+
+	for _, node := range spec.Nodes {
+		for _, dep := range node.DependsOn {
+			from, ok := ids[dep]
+			if !ok {
+				return nil, &GraphError{Dangling: []Edge{{From: dep, To: node.ID}}}
+			}
+			if err := g.AddEdge(from, ids[node.ID]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if spec.BudgetTokens > 0 && total > spec.BudgetTokens {
+		return nil, fmt.Errorf("%w: workflow %q node budgets total %.0f tokens, over its budget of %.0f",
+			ErrValidation, spec.Name, total, spec.BudgetTokens)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// expandTemplate executes a text/template string against vars, failing on
+// references to undefined vars.
+func expandTemplate(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	return buf.String(), nil
+}
@@ -4,4 +4,5 @@ go 1.21
 
 require (
 	github.com/google/uuid v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )